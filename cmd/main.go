@@ -2,17 +2,19 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
+	_ "time/tzdata" // 内置时区数据，导出时按指定时区格式化时间
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	gormLogger "gorm.io/gorm/logger"
 
 	"llmapisrv/config"
 	"llmapisrv/internal/api"
@@ -20,12 +22,17 @@ import (
 	"llmapisrv/internal/api/chat"
 	"llmapisrv/internal/api/dashboard"
 	"llmapisrv/internal/middleware"
+	"llmapisrv/internal/model"
 	"llmapisrv/internal/service"
 	"llmapisrv/pkg/cache"
 	"llmapisrv/pkg/cron"
 	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/money"
+	"llmapisrv/pkg/notifier"
 	"llmapisrv/pkg/oss"
+	"llmapisrv/pkg/payment"
 	"llmapisrv/pkg/queue"
+	"llmapisrv/pkg/tracing"
 )
 
 func main() {
@@ -39,9 +46,21 @@ func main() {
 
 	// 初始化日志
 	logger.Setup(config.AppConfig.Logger)
+	money.Setup(config.AppConfig.Money)
+
+	// 初始化链路追踪
+	shutdownTracing, err := tracing.Setup(config.AppConfig.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownTracing(ctx)
+	}()
 
 	gormConf := &gorm.Config{
-		Logger: gormLogger.Default.LogMode(gormLogger.Info), // 打印所有 SQL,
+		Logger: logger.NewGormLogger(), // 只记录慢查询和错误，经过日志脱敏
 	}
 
 	// 初始化网关数据库
@@ -56,12 +75,26 @@ func main() {
 		log.Fatalf("Failed to connect to New API database: %v", err)
 	}
 
+	// SQL 关联到请求链路
+	if err := gatewayDB.Use(tracing.NewGormPlugin("gateway")); err != nil {
+		log.Fatalf("Failed to register gateway database tracing: %v", err)
+	}
+	if err := newAPIDB.Use(tracing.NewGormPlugin("new_api")); err != nil {
+		log.Fatalf("Failed to register New API database tracing: %v", err)
+	}
+
+	// 迁移网关数据库表结构
+	if err := model.AutoMigrate(gatewayDB); err != nil {
+		log.Fatalf("Failed to migrate gateway database: %v", err)
+	}
+
 	// 初始化Redis
 	redisClient := redis.NewClient(&redis.Options{
 		Addr:     config.AppConfig.Redis.Addr,
 		Password: config.AppConfig.Redis.Password,
 		DB:       config.AppConfig.Redis.DB,
 	})
+	redisClient.AddHook(tracing.RedisHook{})
 
 	// 初始化缓存
 	redisCache := cache.NewRedisCache(redisClient)
@@ -75,42 +108,128 @@ func main() {
 		log.Fatalf("Failed to initialize OSS client: %v", err)
 	}
 
+	// 初始化鉴权缓存，并监听其他副本发出的失效通知
+	authCache := service.NewAuthCacheService(gatewayDB, redisCache)
+	authCache.StartInvalidationListener()
+
+	// 初始化邮件通知
+	emailNotifier, err := notifier.New(config.AppConfig.Email)
+	if err != nil {
+		log.Fatalf("Failed to initialize email notifier: %v", err)
+	}
+
+	// 初始化通知服务
+	notificationService := service.NewNotificationService(gatewayDB, redisCache, redisQueue, &config.AppConfig, emailNotifier)
+
+	// 初始化消费统计
+	spendingService := service.NewSpendingService(gatewayDB, redisCache)
+
 	// 初始化同步服务
-	syncService := service.NewSyncService(gatewayDB, newAPIDB, &config.AppConfig, redisCache)
+	syncService := service.NewSyncService(gatewayDB, newAPIDB, &config.AppConfig, redisCache, authCache, notificationService, spendingService)
+
+	// 初始化日志归档
+	logArchiveService := service.NewLogArchiveService(gatewayDB, ossClient, &config.AppConfig)
 
 	// 初始化服务
-	userService := service.NewUserService(gatewayDB, newAPIDB, redisCache, syncService)
-	logService := service.NewLogService(gatewayDB, newAPIDB, &config.AppConfig)
+	userService := service.NewUserService(gatewayDB, newAPIDB, redisCache, syncService, authCache)
+	requestRecordService := service.NewRequestRecordService(gatewayDB)
+	logService := service.NewLogService(gatewayDB, newAPIDB, &config.AppConfig, logArchiveService, requestRecordService)
 	newAPIService := service.NewNewAPIService(&config.AppConfig, redisCache)
-	modelService := service.NewModelService(gatewayDB, newAPIDB, &config.AppConfig)
-	redemptionService := service.NewRedemptionService(gatewayDB)
+	modelService := service.NewModelService(gatewayDB, newAPIDB, newAPIService, &config.AppConfig)
+	redemptionService := service.NewRedemptionService(gatewayDB, userService, &config.AppConfig)
+	redeemGuard := service.NewRedeemGuardService(redisCache, &config.AppConfig, emailNotifier)
+	apiKeyService := service.NewAPIKeyService(gatewayDB, userService, authCache)
+	adminService := service.NewAdminService(gatewayDB, &config.AppConfig)
+	auditService := service.NewAuditService(gatewayDB)
+	statsService := service.NewStatsService(gatewayDB)
+	captureService := service.NewCaptureService(gatewayDB, authCache, &config.AppConfig)
+	healthService := service.NewHealthService(gatewayDB, newAPIDB, redisCache, newAPIService, ossClient, &config.AppConfig)
+
+	// 启用已配置的支付渠道
+	var paymentProviders []payment.PaymentProvider
+	if mock := config.AppConfig.Payment.Mock; mock.Enabled {
+		// 模拟渠道的支付地址即为已签名的到账通知，只允许在调试模式下启用
+		if config.AppConfig.Server.Mode != gin.DebugMode {
+			log.Fatalf("payment.mock.enabled requires server.mode: debug, refusing to start")
+		}
+		if mock.Secret == "" {
+			log.Fatalf("payment.mock.secret is required when payment.mock.enabled is true")
+		}
+		paymentProviders = append(paymentProviders, payment.NewMockProvider(mock.Secret))
+	}
+	orderService := service.NewOrderService(gatewayDB, userService, &config.AppConfig, paymentProviders...)
 
 	// 初始化处理器
-	statusHandler := api.NewStatusHandler(newAPIService)
-	billingHandler := dashboard.NewBillingHandler(newAPIService, userService)
+	statusHandler := api.NewStatusHandler(newAPIService, healthService)
+	billingHandler := dashboard.NewBillingHandler(newAPIService, userService, spendingService)
 	pricingHandler := api.NewPricingHandler(newAPIService, modelService)
-	chatHandler := chat.NewChatHandler(newAPIService, logService, redisQueue)
-	redemptionHandler := api.NewRedemptionHandler(newAPIService, redemptionService, userService)
+	chatHandler := chat.NewChatHandler(newAPIService, modelService, logService, apiKeyService, spendingService, captureService, requestRecordService, redisQueue)
+	redemptionHandler := api.NewRedemptionHandler(newAPIService, redemptionService, userService, redeemGuard)
 	adminRedemptionHandler := admin.NewRedemptionAdminHandler(redemptionService, userService)
 	adminUploadHandler := admin.NewUploadHandler(ossClient)
+	adminSyncHandler := admin.NewSyncHandler(syncService, emailNotifier)
+	adminAccountHandler := admin.NewAdminAccountHandler(adminService)
+	adminAuditHandler := admin.NewAuditHandler(auditService)
+	adminUsageExportHandler := admin.NewUsageExportHandler(logService)
+	adminStatsHandler := admin.NewStatsHandler(statsService)
+	adminLogArchiveHandler := admin.NewLogArchiveHandler(logArchiveService)
+	adminCaptureHandler := admin.NewCaptureHandler(captureService)
+	adminRequestRecordHandler := admin.NewRequestRecordHandler(requestRecordService)
 	logHandler := api.NewLogHandler(logService)
 	proxyHandler := api.NewProxyHandler(ossClient)
+	apiKeyHandler := api.NewAPIKeyHandler(apiKeyService)
+	orderHandler := api.NewOrderHandler(orderService)
+	notificationHandler := api.NewNotificationHandler(notificationService)
+	spendingHandler := api.NewSpendingHandler(spendingService)
+	captureHandler := api.NewCaptureHandler(captureService)
+	requestRecordHandler := api.NewRequestRecordHandler(requestRecordService)
+
+	// 启动调用日志队列处理
+	redisQueue.StartWorker("log:chat", func(data []byte) error {
+		return logService.ProcessLogFromQueue(data, syncService)
+	})
+
+	// 启动 webhook 投递队列处理和失败重试
+	redisQueue.StartWorker(service.WebhookQueue, notificationService.ProcessDelivery)
+	notificationService.StartRetryLoop()
+	notificationService.StartScanLoop()
+
+	// 定时将日志汇总到 usage_daily
+	statsService.StartRollupLoop(10 * time.Minute)
+
+	// 定时清理过期的内容采集记录
+	captureService.StartCleanupLoop(time.Hour)
 
-	// 启动定时任务
-	cronManager := cron.NewCronManager(logService, modelService, syncService)
-	// cronManager.Start()
+	// 按日志保留天数清理网关调用记录
+	requestRecordService.StartCleanupLoop(config.AppConfig.Log.RetentionDays)
+
+	// 启动定时任务：日志清理、用户和日志全量同步，同步失败时邮件告警
+	cronManager := cron.NewCronManager(logService, modelService, syncService, emailNotifier)
+	cronManager.Start()
 	defer cronManager.Stop()
 
-	// 设置Gin模式
-	gin.SetMode(gin.ReleaseMode)
+	// 设置Gin模式，未配置时为 release
+	if config.AppConfig.Server.Mode == gin.DebugMode {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
 
 	// 创建路由
 	r := gin.Default()
 
+	// 只采信可信代理传来的转发头，防止伪造客户端IP绕过白名单
+	if err := r.SetTrustedProxies(config.AppConfig.Security.TrustedProxies); err != nil {
+		log.Fatalf("Failed to set trusted proxies: %v", err)
+	}
+	if len(config.AppConfig.Security.RemoteIPHeaders) > 0 {
+		r.RemoteIPHeaders = config.AppConfig.Security.RemoteIPHeaders
+	}
+
 	// 全局中间件
 	r.Use(middleware.TraceIDMiddleware())
 	r.Use(middleware.ClientInfoMiddleware())
-	r.Use(middleware.LoggerMiddleware())
+	r.Use(middleware.LoggerMiddleware(config.AppConfig.Logger.LogBodies))
 	r.Use(middleware.MetricsMiddleware())
 
 	// 添加静态文件支持
@@ -145,53 +264,132 @@ func main() {
 	// 注册路由
 	// 健康检查
 	r.GET("/api/about", statusHandler.HealthCheck)
+	r.GET("/healthz", statusHandler.Liveness)
+	r.GET("/readyz", statusHandler.Readiness)
 
 	// Prometheus 指标
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	// 价格查询
 	r.GET("/api/pricing", pricingHandler.GetPricing)
+	r.GET("/api/currency", pricingHandler.GetCurrency)
 
 	// 图片代理接口（公开接口）
 	r.GET("/api/image-proxy", proxyHandler.ImageProxy)
 
+	// 支付渠道异步通知（公开接口，由渠道签名校验）
+	r.POST("/api/payment/notify/:provider", orderHandler.Notify)
+
 	// 认证路由
 	authGroup := r.Group("/")
-	authGroup.Use(middleware.AuthMiddleware(userService, redisCache))
+	authGroup.Use(middleware.AuthMiddleware(apiKeyService, authCache))
 
 	// 账单相关
-	authGroup.GET("/v1/dashboard/billing/subscription", billingHandler.GetSubscription)
-	authGroup.GET("/v1/dashboard/billing/usage", billingHandler.GetUsage)
+	authGroup.GET("/v1/dashboard/billing/subscription", middleware.RequireScope(service.ScopeBillingRead), billingHandler.GetSubscription)
+	authGroup.GET("/v1/dashboard/billing/usage", middleware.RequireScope(service.ScopeBillingRead), billingHandler.GetUsage)
+
+	// 每日、每月消费上限
+	authGroup.GET("/api/spending", middleware.RequireScope(service.ScopeBillingRead), spendingHandler.GetSpending)
+	authGroup.PUT("/api/spending/limits", middleware.RequirePrimaryKey(), spendingHandler.UpdateUserLimits)
 
 	// 聊天完成 openai兼容的接口调用方式
-	authGroup.POST("/v1/chat/completions", chatHandler.ChatCompletions)
+	authGroup.POST("/v1/chat/completions", middleware.RequireScope(service.ScopeChat), middleware.RequireModelAccess(config.AppConfig.LockedModels), chatHandler.ChatCompletions)
 
 	// 兑换码
-	authGroup.GET("/api/redeem", redemptionHandler.RedeemCodeInfo)
-	authGroup.POST("/api/redeem", redemptionHandler.RedeemCode)
+	authGroup.GET("/api/redeem", middleware.RequirePrimaryKey(), middleware.RedeemInfoRateLimiter(redisCache, &config.AppConfig), redemptionHandler.RedeemCodeInfo)
+	authGroup.POST("/api/redeem", middleware.RequirePrimaryKey(), redemptionHandler.RedeemCode)
+
+	// 充值订单（仅主密钥可操作）
+	authGroup.POST("/api/orders", middleware.RequirePrimaryKey(), orderHandler.CreateOrder)
+	authGroup.GET("/api/orders", middleware.RequirePrimaryKey(), orderHandler.ListOrders)
+	authGroup.GET("/api/orders/:order_no", middleware.RequirePrimaryKey(), orderHandler.GetOrder)
+
+	// 余额、到期通知（仅主密钥可操作）
+	notifyGroup := authGroup.Group("/api/notifications")
+	notifyGroup.Use(middleware.RequirePrimaryKey())
+	{
+		notifyGroup.GET("/settings", notificationHandler.GetSettings)
+		notifyGroup.PUT("/settings", notificationHandler.UpdateSettings)
+		notifyGroup.POST("/settings/rotate-secret", notificationHandler.RotateSecret)
+		notifyGroup.POST("/test", notificationHandler.SendTest)
+		notifyGroup.GET("/deliveries", notificationHandler.ListDeliveries)
+	}
 
 	// 日志查询
-	authGroup.GET("/api/logs", logHandler.GetLogs)
+	authGroup.GET("/api/logs", middleware.RequireScope(service.ScopeLogsRead), logHandler.GetLogs)
+	authGroup.GET("/api/logs/summary", middleware.RequireScope(service.ScopeLogsRead), logHandler.GetLogSummary)
+	authGroup.GET("/api/logs/export", middleware.RequireScope(service.ScopeLogsRead), logHandler.ExportLogs)
+	authGroup.GET("/api/requests", middleware.RequireScope(service.ScopeLogsRead), requestRecordHandler.ListRecords)
+
+	// 子密钥管理（仅主密钥可操作）
+	keyGroup := authGroup.Group("/api/keys")
+	keyGroup.Use(middleware.RequirePrimaryKey())
+	{
+		keyGroup.GET("", apiKeyHandler.ListKeys)
+		keyGroup.POST("", apiKeyHandler.CreateKey)
+		keyGroup.DELETE("/:id", apiKeyHandler.RevokeKey)
+		keyGroup.POST("/:id/rotate", apiKeyHandler.RotateKey)
+		keyGroup.PUT("/:id/limits", spendingHandler.UpdateKeyLimits)
+		keyGroup.PUT("/:id/capture", captureHandler.UpdateKeyCapture)
+	}
+
+	// 请求内容采集（仅主密钥可操作）
+	captureGroup := authGroup.Group("/api/captures")
+	captureGroup.Use(middleware.RequirePrimaryKey())
+	{
+		captureGroup.PUT("/settings", captureHandler.UpdateUserCapture)
+		captureGroup.GET("", captureHandler.ListCaptures)
+		captureGroup.GET("/:id", captureHandler.GetCapture)
+		captureGroup.DELETE("", captureHandler.DeleteCaptures)
+	}
 
 	// 管理员路由
 	adminGroup := r.Group("api/admin")
-	adminGroup.Use(middleware.AdminAuthMiddleware(&config.AppConfig))
+	adminGroup.Use(middleware.AdminAuditMiddleware(auditService))
+	adminGroup.Use(middleware.IPAllowlistMiddleware(config.AppConfig.Security.AdminAllowedIPs))
+	adminGroup.Use(middleware.AdminAuthMiddleware(adminService))
 	{
 		// 管理员兑换码管理
-		adminGroup.POST("/redemption/generate", adminRedemptionHandler.GenerateCodes)
-		adminGroup.POST("/quota/add", adminRedemptionHandler.AddQuota)
-
-		// 管理员手动同步
-		adminGroup.POST("/sync/user", admin.NewSyncHandler(syncService).SyncUser)
-		adminGroup.POST("/sync/logs", admin.NewSyncHandler(syncService).SyncLogs)
-		adminGroup.POST("/sync/all", admin.NewSyncHandler(syncService).SyncAll)
-
-		// 管理员手动删除旧日志
-		adminGroup.POST("/cleanup/logs", logHandler.CleanupOldLogs)
+		financeGroup := adminGroup.Group("", middleware.RequireAdminRole(service.AdminRoleFinance))
+		financeGroup.POST("/redemption/generate", adminRedemptionHandler.GenerateCodes)
+		financeGroup.GET("/redemption/batches", adminRedemptionHandler.ListBatches)
+		financeGroup.GET("/redemption/batches/:id", adminRedemptionHandler.GetBatch)
+		financeGroup.POST("/redemption/batches/:id/revoke", adminRedemptionHandler.RevokeBatch)
+		financeGroup.GET("/redemption/batches/:id/export", adminRedemptionHandler.ExportBatch)
+		financeGroup.POST("/redemption/codes/revoke", adminRedemptionHandler.RevokeCode)
+		financeGroup.POST("/quota/add", adminRedemptionHandler.AddQuota)
+		financeGroup.GET("/usage/export", adminUsageExportHandler.ExportUsage)
+
+		// 管理员统计，基于 usage_daily 汇总表
+		financeGroup.GET("/stats/top-users", adminStatsHandler.TopUsers)
+		financeGroup.GET("/stats/top-models", adminStatsHandler.TopModels)
+		financeGroup.GET("/stats/revenue", adminStatsHandler.RevenueTrend)
+		financeGroup.GET("/stats/active-keys", adminStatsHandler.ActiveKeys)
+
+		// 管理员手动同步、删除旧日志
+		operatorGroup := adminGroup.Group("", middleware.RequireAdminRole(service.AdminRoleOperator))
+		operatorGroup.POST("/sync/user", adminSyncHandler.SyncUser)
+		operatorGroup.POST("/sync/logs", adminSyncHandler.SyncLogs)
+		operatorGroup.POST("/sync/all", adminSyncHandler.SyncAll)
+		operatorGroup.POST("/cleanup/logs", logHandler.CleanupOldLogs)
+		operatorGroup.POST("/stats/rollup", adminStatsHandler.Rollup)
+		operatorGroup.GET("/logs/archives", adminLogArchiveHandler.ListArchives)
+		operatorGroup.GET("/logs/archives/:day", adminLogArchiveHandler.QueryArchive)
+		operatorGroup.POST("/logs/archives/:day/restore", adminLogArchiveHandler.RestoreArchive)
+		operatorGroup.GET("/captures", adminCaptureHandler.ListCaptures)
+		operatorGroup.GET("/captures/:id", adminCaptureHandler.GetCapture)
+		operatorGroup.GET("/requests", adminRequestRecordHandler.ListRecords)
 
 		// 管理员图片上传
-		adminGroup.POST("/upload/image", adminUploadHandler.UploadImage)
-
+		uploaderGroup := adminGroup.Group("", middleware.RequireAdminRole(service.AdminRoleUploader))
+		uploaderGroup.POST("/upload/image", adminUploadHandler.UploadImage)
+
+		// 管理员账号管理
+		superGroup := adminGroup.Group("", middleware.RequireAdminRole(service.AdminRoleSuperAdmin))
+		superGroup.GET("/admins", adminAccountHandler.ListAdmins)
+		superGroup.POST("/admins", adminAccountHandler.CreateAdmin)
+		superGroup.DELETE("/admins/:id", adminAccountHandler.RevokeAdmin)
+		superGroup.GET("/audit", adminAuditHandler.GetAuditLogs)
 	}
 
 	// 启动服务
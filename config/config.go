@@ -11,6 +11,7 @@ type Config struct {
 	Server struct {
 		Port int    `yaml:"port"`
 		Host string `yaml:"host"`
+		Mode string `yaml:"mode"` // 运行模式：release（默认）或 debug，模拟支付等调试功能只能在 debug 模式启用
 	} `yaml:"server"`
 
 	NewAPI struct {
@@ -32,13 +33,51 @@ type Config struct {
 	RateLimit struct {
 		BillingQueryLimit int `yaml:"billing_query_limit"` // 每分钟查询次数
 		LogQueryLimit     int `yaml:"log_query_limit"`     // 每分钟日志查询次数
+		RedeemInfoLimit   int `yaml:"redeem_info_limit"`   // 每分钟兑换码查询次数
 	} `yaml:"rate_limit"`
 
+	Redemption struct {
+		MaxFailures       int `yaml:"max_failures"`        // 窗口内允许的失败次数，达到后锁定
+		InfoMaxFailures   int `yaml:"info_max_failures"`   // 查询接口窗口内允许的失败次数，与兑换接口分开计数
+		FailureWindow     int `yaml:"failure_window"`      // 失败计数窗口（秒）
+		LockoutSeconds    int `yaml:"lockout_seconds"`     // 首次锁定时长（秒），再次锁定时翻倍
+		MaxLockoutSeconds int `yaml:"max_lockout_seconds"` // 最长锁定时长（秒）
+		AlertThreshold    int `yaml:"alert_threshold"`     // 单个key一小时内失败次数达到该值时告警
+	} `yaml:"redemption"`
+
+	Admin struct {
+		BootstrapToken string `yaml:"bootstrap_token"` // 初始超级管理员token，只能用于创建第一个超级管理员，存在可用的超级管理员后失效
+	} `yaml:"admin"`
+
+	Security struct {
+		TrustedProxies  []string `yaml:"trusted_proxies"`   // 可信代理IP/CIDR，只有来自这些地址的转发头才会被采信
+		RemoteIPHeaders []string `yaml:"remote_ip_headers"` // 获取客户端IP的转发头，按顺序尝试
+		AdminAllowedIPs []string `yaml:"admin_allowed_ips"` // 管理员接口IP/CIDR白名单，为空不限制
+	} `yaml:"security"`
+
 	Log struct {
-		RetentionDays int `yaml:"retention_days"` // 日志保留天数
+		RetentionDays   int    `yaml:"retention_days"`    // 日志保留天数
+		DeleteBatchSize int    `yaml:"delete_batch_size"` // 清理时每批删除的行数
+		ArchiveEnabled  bool   `yaml:"archive_enabled"`   // 清理前是否归档到 OSS
+		ArchivePrefix   string `yaml:"archive_prefix"`    // 归档文件在 OSS 中的目录
 	} `yaml:"log"`
 
+	Notification struct {
+		MaxAttempts         int  `yaml:"max_attempts"`          // webhook 最多投递次数
+		TimeoutSeconds      int  `yaml:"timeout_seconds"`       // 单次投递超时（秒）
+		AllowPrivateTargets bool `yaml:"allow_private_targets"` // 是否允许投递到内网地址
+		ScanIntervalMinutes int  `yaml:"scan_interval_minutes"` // 定时检查余额和到期时间的间隔（分钟）
+	} `yaml:"notification"`
+
+	Email   Email   `yaml:"email"`
+	Money   Money   `yaml:"money"`
+	Payment Payment `yaml:"payment"`
+	Capture Capture `yaml:"capture"`
+	Tracing Tracing `yaml:"tracing"`
+	Health  Health  `yaml:"health"`
+
 	ModelMapping map[string][]string `yaml:"model_mapping"` // 模型映射关系
+	LockedModels []string            `yaml:"locked_models"` // 需通过兑换码解锁才能使用的显示模型
 	Logger       Logger              `yaml:"logger"`
 	OSS          OSS                 `yaml:"oss"`
 }
@@ -46,12 +85,80 @@ type Config struct {
 var AppConfig Config
 
 type Logger struct {
-	Level      string `yaml:"level"` // debug, info, warn, error
-	Filename   string `yaml:"filename"`
-	MaxSize    int    `yaml:"maxsize"`    // MB
-	MaxBackups int    `yaml:"maxbackups"` // 文件个数
-	MaxAge     int    `yaml:"maxage"`     // 天数
-	Compress   bool   `yaml:"compress"`   // 是否压缩
+	Level      string       `yaml:"level"` // debug, info, warn, error
+	Filename   string       `yaml:"filename"`
+	MaxSize    int          `yaml:"maxsize"`    // MB
+	MaxBackups int          `yaml:"maxbackups"` // 文件个数
+	MaxAge     int          `yaml:"maxage"`     // 天数
+	Compress   bool         `yaml:"compress"`   // 是否压缩
+	LogBodies  bool         `yaml:"log_bodies"` // 是否在访问日志中记录脱敏后的请求体，默认只记录大小
+	Redaction  LogRedaction `yaml:"redaction"`
+}
+
+// LogRedaction 日志脱敏规则，审计日志和内容采集共用，列表为空时使用内置默认值
+type LogRedaction struct {
+	Disabled        bool     `yaml:"disabled"`         // 关闭日志输出的脱敏，仅用于本地调试，审计日志和内容采集仍会脱敏
+	HeaderAllowlist []string `yaml:"header_allowlist"` // 访问日志记录的请求头，未列出的不记录
+	HeaderDenylist  []string `yaml:"header_denylist"`  // 只记录首尾几位的请求头，同名日志字段同样脱敏
+	JSONPaths       []string `yaml:"json_paths"`       // 脱敏的 JSON 路径，如 api_key、messages[*].content，$. 开头时从根节点匹配
+	Patterns        []string `yaml:"patterns"`         // 在内置规则之外追加的正则，有分组时只脱敏最后一个分组
+}
+
+type Email struct {
+	Enabled         bool            `yaml:"enabled"`
+	Host            string          `yaml:"host"`
+	Port            int             `yaml:"port"`
+	Username        string          `yaml:"username"` // 为空时不认证
+	Password        string          `yaml:"password"`
+	From            string          `yaml:"from"`
+	ImplicitTLS     bool            `yaml:"implicit_tls"`     // 465端口使用隐式TLS，否则服务端支持时使用STARTTLS
+	Language        string          `yaml:"language"`         // 默认模板语言：zh, en
+	AdminRecipients []string        `yaml:"admin_recipients"` // 管理员告警收件人
+	Events          map[string]bool `yaml:"events"`           // 按事件启用，未列出的事件不发送
+}
+
+type Capture struct {
+	TTLHours           int `yaml:"ttl_hours"`            // 采集内容保留时长（小时）
+	MaxBytes           int `yaml:"max_bytes"`            // 请求、回复内容各自的最大字节数，超出截断
+	MaxWindowHours     int `yaml:"max_window_hours"`     // 单次开启采集的最长时长（小时）
+	DefaultWindowHours int `yaml:"default_window_hours"` // 未指定时长时默认开启的时长（小时）
+}
+
+type Tracing struct {
+	Enabled     bool    `yaml:"enabled"`      // 是否导出链路追踪，关闭时仍生成 trace id 并透传 traceparent
+	Exporter    string  `yaml:"exporter"`     // otlp 或 stdout
+	Endpoint    string  `yaml:"endpoint"`     // OTLP/HTTP 地址，如 localhost:4318，为空时读取 OTEL_EXPORTER_OTLP_ENDPOINT
+	Insecure    bool    `yaml:"insecure"`     // OTLP 是否使用 HTTP 明文
+	ServiceName string  `yaml:"service_name"` // 上报的服务名
+	SampleRatio float64 `yaml:"sample_ratio"` // 采样比例（0-1），上游已采样的请求始终采样
+}
+
+type Health struct {
+	TimeoutMS    int            `yaml:"timeout_ms"`    // 单个依赖检查的默认超时（毫秒）
+	Timeouts     map[string]int `yaml:"timeouts"`      // 按依赖单独设置的超时（毫秒），如 new_api: 3000
+	CacheSeconds int            `yaml:"cache_seconds"` // 检查结果缓存时长（秒），避免状态页频繁刷新时压垮依赖
+}
+
+type Money struct {
+	QuotaPerUSD     int64   `yaml:"quota_per_usd"`     // 每美元额度，需与 New API 保持一致
+	DisplayCurrency string  `yaml:"display_currency"`  // 展示货币，如 USD、CNY
+	CurrencySymbol  string  `yaml:"currency_symbol"`   // 展示货币符号
+	USDExchangeRate float64 `yaml:"usd_exchange_rate"` // 1美元折合的展示货币
+}
+
+type Payment struct {
+	Provider           string      `yaml:"provider"`             // 默认支付渠道
+	NotifyBaseURL      string      `yaml:"notify_base_url"`      // 网关对外地址，用于拼接异步通知地址
+	QuotaPerYuan       int64       `yaml:"quota_per_yuan"`       // 每元充值到账的额度，为空时仅在展示货币为 CNY 时按展示货币换算，否则拒绝下单
+	MinAmount          int64       `yaml:"min_amount"`           // 单笔最小金额（分）
+	MaxAmount          int64       `yaml:"max_amount"`           // 单笔最大金额（分）
+	OrderExpireMinutes int         `yaml:"order_expire_minutes"` // 订单支付有效期（分钟）
+	Mock               MockPayment `yaml:"mock"`
+}
+
+type MockPayment struct {
+	Enabled bool   `yaml:"enabled"` // 是否启用模拟渠道，仅允许在 server.mode 为 debug 时启用
+	Secret  string `yaml:"secret"`  // 模拟渠道签名密钥
 }
 
 type OSS struct {
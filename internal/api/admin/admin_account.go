@@ -0,0 +1,79 @@
+// internal/api/admin/admin_account.go
+package admin
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/internal/model"
+	"llmapisrv/internal/service"
+	"llmapisrv/pkg/util"
+)
+
+type CreateAdminRequest struct {
+	Name string `json:"name" binding:"required,max=64"`
+	Role string `json:"role" binding:"required"` // super_admin, finance, operator, uploader
+}
+
+type AdminAccountHandler struct {
+	adminService *service.AdminService
+}
+
+func NewAdminAccountHandler(adminService *service.AdminService) *AdminAccountHandler {
+	return &AdminAccountHandler{
+		adminService: adminService,
+	}
+}
+
+// CreateAdmin 创建管理员，token仅在创建时返回
+func (h *AdminAccountHandler) CreateAdmin(c *gin.Context) {
+	var req CreateAdminRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	admin, token, err := h.adminService.CreateAdmin(req.Name, req.Role, c.MustGet("admin").(*model.Admin))
+	if errors.Is(err, service.ErrBootstrapRole) {
+		util.Fail(c, util.ForbiddenCode, err.Error())
+		return
+	}
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, gin.H{
+		"admin": admin,
+		"token": token,
+	})
+}
+
+// ListAdmins 获取管理员列表
+func (h *AdminAccountHandler) ListAdmins(c *gin.Context) {
+	admins, err := h.adminService.ListAdmins()
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, admins)
+}
+
+// RevokeAdmin 吊销管理员token
+func (h *AdminAccountHandler) RevokeAdmin(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		util.ParamError(c, "invalid id")
+		return
+	}
+
+	if err := h.adminService.RevokeAdmin(uint(id)); err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, "Admin revoked")
+}
@@ -0,0 +1,86 @@
+// internal/api/admin/audit.go
+package admin
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/internal/service"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/util"
+)
+
+type AuditHandler struct {
+	auditService *service.AuditService
+}
+
+func NewAuditHandler(auditService *service.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// GetAuditLogs 查询审计日志，format=csv 时导出CSV
+func (h *AuditHandler) GetAuditLogs(c *gin.Context) {
+	startTime, err := parseUnixQuery(c, "start_time")
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+	endTime, err := parseUnixQuery(c, "end_time")
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+	filter := service.AuditFilter{
+		AdminName: c.Query("admin_name"),
+		Action:    c.Query("action"),
+		Result:    c.Query("result"),
+		StartTime: startTime,
+		EndTime:   endTime,
+	}
+
+	if c.Query("format") == "csv" {
+		fileName := fmt.Sprintf("admin_audit_%s.csv", time.Now().Format("20060102_150405"))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", "attachment; filename="+fileName)
+		if err := h.auditService.ExportCSV(filter, c.Writer); err != nil {
+			logger.ErrorWithCtx(c.Request.Context(), "Failed to export audit logs", err)
+		}
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	logs, total, err := h.auditService.Query(filter, page, pageSize)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.PageSuccess(c, logs, total, page, pageSize)
+}
+
+// parseUnixQuery 解析 unix 秒时间参数，未传时返回0表示不限制，格式错误时返回错误，避免被当作不限制导出全部记录
+func parseUnixQuery(c *gin.Context, key string) (int64, error) {
+	v := c.Query(key)
+	if v == "" {
+		return 0, nil
+	}
+	ts, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || ts < 0 {
+		return 0, fmt.Errorf("invalid %s", key)
+	}
+	return ts, nil
+}
@@ -0,0 +1,59 @@
+// internal/api/admin/capture.go
+package admin
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/internal/service"
+	"llmapisrv/pkg/util"
+)
+
+type CaptureHandler struct {
+	captureService *service.CaptureService
+}
+
+func NewCaptureHandler(captureService *service.CaptureService) *CaptureHandler {
+	return &CaptureHandler{
+		captureService: captureService,
+	}
+}
+
+// ListCaptures 分页获取用户开启采集后保存的记录，可按 user_id 过滤
+func (h *CaptureHandler) ListCaptures(c *gin.Context) {
+	userID, _ := strconv.ParseUint(c.Query("user_id"), 10, 64)
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	captures, total, err := h.captureService.ListCaptures(uint(userID), page, pageSize)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.PageSuccess(c, captures, total, page, pageSize)
+}
+
+// GetCapture 获取采集记录的请求和回复内容
+func (h *CaptureHandler) GetCapture(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		util.ParamError(c, "invalid id")
+		return
+	}
+
+	capture, err := h.captureService.GetCapture(0, uint(id))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, capture)
+}
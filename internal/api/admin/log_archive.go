@@ -0,0 +1,86 @@
+// internal/api/admin/log_archive.go
+package admin
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/internal/service"
+	"llmapisrv/pkg/util"
+)
+
+type LogArchiveHandler struct {
+	archiveService *service.LogArchiveService
+}
+
+func NewLogArchiveHandler(archiveService *service.LogArchiveService) *LogArchiveHandler {
+	return &LogArchiveHandler{
+		archiveService: archiveService,
+	}
+}
+
+// ListArchives 分页列出归档文件
+func (h *LogArchiveHandler) ListArchives(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	archives, total, err := h.archiveService.ListArchives(page, pageSize)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.PageSuccess(c, archives, total, page, pageSize)
+}
+
+// QueryArchive 查询某天的归档日志，可按 user_id、model 过滤，最多返回 limit 行
+func (h *LogArchiveHandler) QueryArchive(c *gin.Context) {
+	day := c.Param("day")
+	if _, err := time.Parse(service.StatsDayFormat, day); err != nil {
+		util.ParamError(c, "invalid day")
+		return
+	}
+
+	userID, _ := strconv.ParseUint(c.Query("user_id"), 10, 64)
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	logs, err := h.archiveService.QueryDay(c.Request.Context(), day, service.ArchiveQuery{
+		UserID: uint(userID),
+		Model:  c.Query("model"),
+		Limit:  limit,
+	})
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, logs)
+}
+
+// RestoreArchive 将某天的归档日志恢复到日志表
+func (h *LogArchiveHandler) RestoreArchive(c *gin.Context) {
+	day := c.Param("day")
+	if _, err := time.Parse(service.StatsDayFormat, day); err != nil {
+		util.ParamError(c, "invalid day")
+		return
+	}
+
+	restored, err := h.archiveService.RestoreDay(c.Request.Context(), day)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, gin.H{"day": day, "restored": restored})
+}
@@ -2,18 +2,40 @@
 package admin
 
 import (
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
 
+	"llmapisrv/internal/model"
 	"llmapisrv/internal/service"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/money"
 	"llmapisrv/pkg/util"
 
 	"github.com/gin-gonic/gin"
 )
 
 type GenerateCodesRequest struct {
-	Count    int    `json:"count" binding:"required,min=1,max=1000"`
-	Quota    int64  `json:"quota" binding:"required,min=1"`
-	BatchNum string `json:"batch_num"` // 批次号，可选
+	Name         string   `json:"name"` // 批次名称，可选
+	Count        int      `json:"count" binding:"required,min=1,max=1000"`
+	Type         string   `json:"type"`                                     // 兑换码类型：quota（默认）, subscription, tier, model
+	Quota        int64    `json:"quota" binding:"min=0"`                    // 额度，quota 类型必填
+	Days         int      `json:"days" binding:"min=0"`                     // 延长天数或等级、模型的有效天数
+	Tier         string   `json:"tier"`                                     // tier 类型升级到的等级
+	Models       []string `json:"models"`                                   // model 类型解锁的显示模型
+	BatchNum     string   `json:"batch_num"`                                // 批次号，可选
+	Code         string   `json:"code"`                                     // 自定义兑换码，可选，仅 count 为1时可用
+	MaxUses      *int     `json:"max_uses" binding:"omitempty,min=0"`       // 每个兑换码可兑换总次数，默认1，0为不限制
+	PerUserLimit *int     `json:"per_user_limit" binding:"omitempty,min=0"` // 每个用户可兑换次数，多次兑换码默认1，0为不限制
+	NewUserOnly  bool     `json:"new_user_only"`                            // 仅限活动开始后创建的账户
+	StartTime    int64    `json:"start_time" binding:"min=0"`               // 生效时间戳，0为立即生效
+	EndTime      int64    `json:"end_time" binding:"min=0"`                 // 过期时间戳，0为永不过期
+}
+
+type RevokeCodeRequest struct {
+	Code string `json:"code" binding:"required"`
 }
 
 type AddQuotaRequest struct {
@@ -41,18 +63,61 @@ func (h *RedemptionAdminHandler) GenerateCodes(c *gin.Context) {
 		return
 	}
 
+	if req.EndTime > 0 && req.EndTime <= req.StartTime {
+		util.ParamError(c, "end_time must be later than start_time")
+		return
+	}
+
+	maxUses := 1
+	if req.MaxUses != nil {
+		maxUses = *req.MaxUses
+	}
+
+	// 多次兑换码默认每个用户只能兑换一次，不限次数需显式传 0
+	perUserLimit := 0
+	if maxUses != 1 {
+		perUserLimit = 1
+	}
+	if req.PerUserLimit != nil {
+		perUserLimit = *req.PerUserLimit
+	}
+
 	// 生成兑换码
-	codes, err := h.redemptionService.GenerateCodes(req.Count, req.Quota, req.BatchNum)
+	batch, codes, err := h.redemptionService.GenerateCodes(service.GenerateCodesParams{
+		Name:     req.Name,
+		BatchNum: req.BatchNum,
+		Code:     req.Code,
+		Count:    req.Count,
+		Type:     req.Type,
+		Quota:    req.Quota,
+		Effect: model.RedemptionEffect{
+			Days:   req.Days,
+			Tier:   req.Tier,
+			Models: req.Models,
+		},
+		MaxUses:      maxUses,
+		PerUserLimit: perUserLimit,
+		NewUserOnly:  req.NewUserOnly,
+		StartTime:    req.StartTime,
+		EndTime:      req.EndTime,
+		CreatedBy:    c.GetString("admin_name"),
+	})
+	if errors.Is(err, service.ErrBatchNumExists) {
+		util.Fail(c, util.ConflictCode, err.Error())
+		return
+	}
 	if err != nil {
 		util.ParamError(c, err.Error())
 		return
 	}
 
 	util.Success(c, gin.H{
-		"codes":  codes,
-		"count":  len(codes),
-		"quota":  req.Quota,
-		"amount": float64(req.Quota) / 100.0, // 转换为美元显示
+		"batch":     batch,
+		"codes":     codes,
+		"count":     len(codes),
+		"quota":     req.Quota,
+		"amount":    float64(req.Quota) / 100.0, // 旧版字段，保留兼容
+		"amount_v2": money.FromQuota(req.Quota),
 	})
 }
 
@@ -78,10 +143,105 @@ func (h *RedemptionAdminHandler) AddQuota(c *gin.Context) {
 	}
 
 	util.Success(c, gin.H{
-		"api_key":        req.APIKey,
-		"quota_added":    req.Quota,
-		"amount":         req.Quota, // 转换为美元显示
-		"current_quota":  user.RemainQuota + req.Quota,
-		"current_amount": user.RemainQuota + req.Quota,
+		"api_key":           req.APIKey,
+		"quota_added":       req.Quota,
+		"amount":            req.Quota, // 旧版字段，值为原始额度，保留兼容
+		"current_quota":     user.RemainQuota + req.Quota,
+		"current_amount":    user.RemainQuota + req.Quota, // 旧版字段，值为原始额度，保留兼容
+		"amount_v2":         money.FromQuota(req.Quota),
+		"current_amount_v2": money.FromQuota(user.RemainQuota + req.Quota),
 	})
 }
+
+// ListBatches 批次列表及兑换进度
+func (h *RedemptionAdminHandler) ListBatches(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	batches, total, err := h.redemptionService.ListBatches(page, pageSize)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.PageSuccess(c, batches, total, page, pageSize)
+}
+
+// GetBatch 批次详情及兑换进度
+func (h *RedemptionAdminHandler) GetBatch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		util.ParamError(c, "invalid id")
+		return
+	}
+
+	batch, err := h.redemptionService.GetBatch(uint(id))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, batch)
+}
+
+// RevokeBatch 吊销整个批次
+func (h *RedemptionAdminHandler) RevokeBatch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		util.ParamError(c, "invalid id")
+		return
+	}
+
+	if err := h.redemptionService.RevokeBatch(uint(id)); err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, "Batch revoked")
+}
+
+// RevokeCode 吊销单个兑换码
+func (h *RedemptionAdminHandler) RevokeCode(c *gin.Context) {
+	var req RevokeCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	if err := h.redemptionService.RevokeCode(req.Code); err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, "Code revoked")
+}
+
+// ExportBatch 导出批次中未使用的兑换码（CSV）
+func (h *RedemptionAdminHandler) ExportBatch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		util.ParamError(c, "invalid id")
+		return
+	}
+
+	batch, err := h.redemptionService.GetBatch(uint(id))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": fmt.Sprintf("redemption_%s.csv", batch.BatchNum),
+	}))
+	if err := h.redemptionService.ExportUnusedCodes(batch.ID, c.Writer); err != nil {
+		logger.ErrorWithCtx(c.Request.Context(), "Failed to export redemption codes", err)
+	}
+}
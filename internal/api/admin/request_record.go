@@ -0,0 +1,55 @@
+// internal/api/admin/request_record.go
+package admin
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/internal/service"
+	"llmapisrv/pkg/util"
+)
+
+type RequestRecordHandler struct {
+	recordService *service.RequestRecordService
+}
+
+func NewRequestRecordHandler(recordService *service.RequestRecordService) *RequestRecordHandler {
+	return &RequestRecordHandler{
+		recordService: recordService,
+	}
+}
+
+// ListRecords 分页查询网关调用记录，可按 user_id、trace_id、status 等过滤
+func (h *RequestRecordHandler) ListRecords(c *gin.Context) {
+	filter, err := service.ParseRequestRecordFilter(c.Request.URL.Query())
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+	if v := c.Query("user_id"); v != "" {
+		userID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			util.ParamError(c, "invalid user_id")
+			return
+		}
+		filter.UserID = uint(userID)
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	records, total, err := h.recordService.ListRecords(filter, page, pageSize)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.PageSuccess(c, records, total, page, pageSize)
+}
@@ -1 +1,161 @@
+// internal/api/admin/stats.go
 package admin
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/internal/service"
+	"llmapisrv/pkg/util"
+)
+
+// 统计接口最多查询的天数
+const statsMaxDays = 366
+
+type RollupRequest struct {
+	StartDate string `json:"start_date" binding:"required"` // 2006-01-02
+	EndDate   string `json:"end_date" binding:"required"`
+}
+
+type StatsHandler struct {
+	statsService *service.StatsService
+}
+
+func NewStatsHandler(statsService *service.StatsService) *StatsHandler {
+	return &StatsHandler{
+		statsService: statsService,
+	}
+}
+
+// TopUsers 消耗额度最多的用户
+func (h *StatsHandler) TopUsers(c *gin.Context) {
+	startDay, endDay, err := parseStatsRange(c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	stats, err := h.statsService.TopUsers(startDay, endDay, parseStatsLimit(c))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, stats)
+}
+
+// TopModels 消耗额度最多的模型
+func (h *StatsHandler) TopModels(c *gin.Context) {
+	startDay, endDay, err := parseStatsRange(c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	stats, err := h.statsService.TopModels(startDay, endDay, parseStatsLimit(c))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, stats)
+}
+
+// RevenueTrend 每日消费趋势
+func (h *StatsHandler) RevenueTrend(c *gin.Context) {
+	startDay, endDay, err := parseStatsRange(c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	points, err := h.statsService.RevenueTrend(startDay, endDay)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, points)
+}
+
+// ActiveKeys 每日活跃用户和密钥数
+func (h *StatsHandler) ActiveKeys(c *gin.Context) {
+	startDay, endDay, err := parseStatsRange(c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	points, err := h.statsService.ActiveKeys(startDay, endDay)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, points)
+}
+
+// Rollup 重新汇总指定日期区间的日志，用于补齐历史数据
+func (h *StatsHandler) Rollup(c *gin.Context) {
+	var req RollupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	startDay, endDay, err := parseStatsRange(req.StartDate, req.EndDate)
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	start, _ := time.ParseInLocation(service.StatsDayFormat, startDay, time.Local)
+	end, _ := time.ParseInLocation(service.StatsDayFormat, endDay, time.Local)
+	if err := h.statsService.Rollup(start, end); err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, "Rollup finished")
+}
+
+// parseStatsRange 解析日期区间，默认最近30天（含今天）
+func parseStatsRange(startDate, endDate string) (string, string, error) {
+	end := time.Now()
+	if endDate != "" {
+		t, err := time.ParseInLocation(service.StatsDayFormat, endDate, time.Local)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid end_date")
+		}
+		end = t
+	}
+
+	start := end.AddDate(0, 0, -29)
+	if startDate != "" {
+		t, err := time.ParseInLocation(service.StatsDayFormat, startDate, time.Local)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid start_date")
+		}
+		start = t
+	}
+
+	startDay, endDay := start.Format(service.StatsDayFormat), end.Format(service.StatsDayFormat)
+	if startDay > endDay {
+		return "", "", fmt.Errorf("start_date must not be after end_date")
+	}
+	if end.Sub(start) > statsMaxDays*24*time.Hour {
+		return "", "", fmt.Errorf("date range must not exceed %d days", statsMaxDays)
+	}
+	return startDay, endDay, nil
+}
+
+func parseStatsLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		return 10
+	}
+	return limit
+}
@@ -2,9 +2,14 @@
 package admin
 
 import (
+	"context"
+	"time"
+
 	"github.com/gin-gonic/gin"
 
 	"llmapisrv/internal/service"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/notifier"
 	"llmapisrv/pkg/util"
 )
 
@@ -14,11 +19,13 @@ type SyncRequest struct {
 
 type SyncHandler struct {
 	syncService *service.SyncService
+	notifier    *notifier.Notifier
 }
 
-func NewSyncHandler(syncService *service.SyncService) *SyncHandler {
+func NewSyncHandler(syncService *service.SyncService, notifier *notifier.Notifier) *SyncHandler {
 	return &SyncHandler{
 		syncService: syncService,
+		notifier:    notifier,
 	}
 }
 
@@ -39,6 +46,7 @@ func (h *SyncHandler) SyncUser(c *gin.Context) {
 
 	// 同步所有用户
 	if err := h.syncService.SyncAllUsers(); err != nil {
+		h.notifySyncFailed("sync_users", err)
 		util.Fail(c, util.FailCode, err.Error())
 		return
 	}
@@ -68,6 +76,7 @@ func (h *SyncHandler) SyncLogs(c *gin.Context) {
 
 	// 同步所有日志
 	if err := h.syncService.SyncAllLogs(); err != nil {
+		h.notifySyncFailed("sync_logs", err)
 		util.Fail(c, util.FailCode, err.Error())
 		return
 	}
@@ -79,15 +88,33 @@ func (h *SyncHandler) SyncLogs(c *gin.Context) {
 func (h *SyncHandler) SyncAll(c *gin.Context) {
 	// 先同步所有用户
 	if err := h.syncService.SyncAllUsers(); err != nil {
-		util.Fail(c, util.FailCode, "Failed to sync logs: "+err.Error())
+		h.notifySyncFailed("sync_users", err)
+		util.Fail(c, util.FailCode, "Failed to sync users: "+err.Error())
 		return
 	}
 
 	// 再同步所有日志
 	if err := h.syncService.SyncAllLogs(); err != nil {
+		h.notifySyncFailed("sync_logs", err)
 		util.Fail(c, util.FailCode, "Failed to sync logs: "+err.Error())
 		return
 	}
 
 	util.Success(c, "All data synced successfully")
 }
+
+// notifySyncFailed 异步邮件告警管理员，不阻塞接口返回
+func (h *SyncHandler) notifySyncFailed(task string, err error) {
+	data := map[string]interface{}{
+		"Task":  task,
+		"Time":  time.Now().Format("2006-01-02 15:04:05"),
+		"Error": err.Error(),
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := h.notifier.NotifyAdmins(ctx, notifier.EventSyncFailed, data); err != nil {
+			logger.Errorf("SyncHandler notify admins failed: %v", err)
+		}
+	}()
+}
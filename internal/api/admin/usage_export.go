@@ -0,0 +1,68 @@
+// internal/api/admin/usage_export.go
+package admin
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/internal/service"
+	"llmapisrv/pkg/export"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/util"
+)
+
+type UsageExportHandler struct {
+	logService *service.LogService
+}
+
+func NewUsageExportHandler(logService *service.LogService) *UsageExportHandler {
+	return &UsageExportHandler{
+		logService: logService,
+	}
+}
+
+// ExportUsage 导出指定用户或所有用户的调用日志
+// 参数：user_id（可选，为空导出所有用户）、format（csv/xlsx）、tz（时区名）及日志过滤参数
+func (h *UsageExportHandler) ExportUsage(c *gin.Context) {
+	var userID uint64
+	if v := c.Query("user_id"); v != "" {
+		var err error
+		if userID, err = strconv.ParseUint(v, 10, 64); err != nil || userID == 0 {
+			util.ParamError(c, "invalid user_id")
+			return
+		}
+	}
+
+	filter, err := service.ParseLogFilter(c.Request.URL.Query())
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	format := c.DefaultQuery("format", export.FormatCSV)
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "Local"))
+	if err != nil {
+		util.ParamError(c, "invalid tz")
+		return
+	}
+
+	writer, err := export.NewRowWriter(format, c.Writer)
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	scope := "all"
+	if userID > 0 {
+		scope = "user_" + strconv.FormatUint(userID, 10)
+	}
+	fileName := fmt.Sprintf("usage_%s_%s.%s", scope, time.Now().Format("20060102_150405"), format)
+	c.Header("Content-Type", export.ContentType(format))
+	c.Header("Content-Disposition", "attachment; filename="+fileName)
+	if err := h.logService.ExportLogs(uint(userID), filter, loc, writer); err != nil {
+		logger.ErrorWithCtx(c.Request.Context(), "Failed to export usage", err)
+	}
+}
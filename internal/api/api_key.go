@@ -0,0 +1,108 @@
+// internal/api/api_key.go
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/internal/model"
+	"llmapisrv/internal/service"
+	"llmapisrv/pkg/util"
+)
+
+type CreateAPIKeyRequest struct {
+	Name        string   `json:"name" binding:"required,max=64"`
+	Scopes      []string `json:"scopes" binding:"required"`    // chat, billing:read, logs:read
+	AllowedIPs  []string `json:"allowed_ips"`                  // IP/CIDR白名单，可选
+	QuotaLimit  int64    `json:"quota_limit" binding:"min=0"`  // 消费上限，0为不限制
+	ExpiredTime int64    `json:"expired_time" binding:"min=0"` // 过期时间戳，0为永不过期
+}
+
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// CreateKey 创建子密钥，完整key仅在创建时返回
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	apiKey, err := h.apiKeyService.CreateKey(c.GetUint("user_id"), service.CreateAPIKeyParams{
+		Name:        req.Name,
+		Scopes:      req.Scopes,
+		AllowedIPs:  req.AllowedIPs,
+		QuotaLimit:  req.QuotaLimit,
+		ExpiredTime: req.ExpiredTime,
+	})
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	apiKey.Key = "sk-" + apiKey.Key
+	util.Success(c, apiKey)
+}
+
+// ListKeys 获取子密钥列表，key做脱敏处理
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.ListKeys(c.GetUint("user_id"))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	for i := range keys {
+		keys[i].Key = maskKey(keys[i].KeyPrefix)
+	}
+
+	util.Success(c, keys)
+}
+
+// RevokeKey 吊销子密钥
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		util.ParamError(c, "invalid id")
+		return
+	}
+
+	if err := h.apiKeyService.RevokeKey(c.GetUint("user_id"), uint(id)); err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, "API key revoked")
+}
+
+// RotateKey 轮换子密钥，返回新的完整key
+func (h *APIKeyHandler) RotateKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		util.ParamError(c, "invalid id")
+		return
+	}
+
+	var apiKey *model.APIKey
+	if apiKey, err = h.apiKeyService.RotateKey(c.GetUint("user_id"), uint(id)); err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	apiKey.Key = "sk-" + apiKey.Key
+	util.Success(c, apiKey)
+}
+
+// maskKey 由key的前缀生成脱敏后的展示值
+func maskKey(prefix string) string {
+	return "sk-" + prefix + "****"
+}
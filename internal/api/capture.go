@@ -0,0 +1,125 @@
+// internal/api/capture.go
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/internal/service"
+	"llmapisrv/pkg/util"
+)
+
+type UpdateCaptureRequest struct {
+	Enabled bool `json:"enabled"`
+	Hours   int  `json:"hours" binding:"min=0"` // 开启时长（小时），为0使用默认时长
+}
+
+type CaptureHandler struct {
+	captureService *service.CaptureService
+}
+
+func NewCaptureHandler(captureService *service.CaptureService) *CaptureHandler {
+	return &CaptureHandler{
+		captureService: captureService,
+	}
+}
+
+// UpdateUserCapture 开启或关闭主密钥的内容采集，子密钥需单独开启
+func (h *CaptureHandler) UpdateUserCapture(c *gin.Context) {
+	var req UpdateCaptureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	captureUntil, err := h.captureService.SetUserCapture(c.GetUint("user_id"), h.captureHours(req))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, gin.H{"capture_until": captureUntil})
+}
+
+// UpdateKeyCapture 开启或关闭子密钥的内容采集
+func (h *CaptureHandler) UpdateKeyCapture(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		util.ParamError(c, "invalid id")
+		return
+	}
+
+	var req UpdateCaptureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	apiKey, err := h.captureService.SetKeyCapture(c.GetUint("user_id"), uint(id), h.captureHours(req))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	apiKey.Key = maskKey(apiKey.KeyPrefix)
+	util.Success(c, apiKey)
+}
+
+func (h *CaptureHandler) captureHours(req UpdateCaptureRequest) int {
+	if !req.Enabled {
+		return 0
+	}
+	if req.Hours == 0 {
+		return h.captureService.DefaultWindowHours()
+	}
+	return req.Hours
+}
+
+// ListCaptures 分页获取采集记录，不含内容
+func (h *CaptureHandler) ListCaptures(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	captures, total, err := h.captureService.ListCaptures(c.GetUint("user_id"), page, pageSize)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.PageSuccess(c, captures, total, page, pageSize)
+}
+
+// GetCapture 获取采集记录的请求和回复内容
+func (h *CaptureHandler) GetCapture(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		util.ParamError(c, "invalid id")
+		return
+	}
+
+	capture, err := h.captureService.GetCapture(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, capture)
+}
+
+// DeleteCaptures 删除账户下的所有采集记录
+func (h *CaptureHandler) DeleteCaptures(c *gin.Context) {
+	deleted, err := h.captureService.DeleteUserCaptures(c.GetUint("user_id"))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, gin.H{"deleted": deleted})
+}
@@ -0,0 +1,98 @@
+// internal/api/chat/capture.go
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// completionChunk 回复中与采集相关的字段，兼容流式分片和非流式响应
+type completionChunk struct {
+	Choices []struct {
+		Index   int `json:"index"`
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// captureCollector 收集回复内容，流式响应按分片拼接，只采集第一个候选
+type captureCollector struct {
+	completion       strings.Builder
+	raw              bytes.Buffer // 无法解析的内容，如上游错误信息
+	finishReason     string
+	promptTokens     int
+	completionTokens int
+}
+
+// addChunk 处理一行 SSE 数据
+func (cc *captureCollector) addChunk(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		cc.raw.Write(line)
+		cc.raw.WriteByte('\n')
+		return
+	}
+
+	data := bytes.TrimSpace(line[len("data:"):])
+	if bytes.Equal(data, []byte("[DONE]")) {
+		return
+	}
+	var chunk completionChunk
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		cc.raw.Write(data)
+		cc.raw.WriteByte('\n')
+		return
+	}
+	cc.add(&chunk, true)
+}
+
+// setResponse 处理非流式响应体
+func (cc *captureCollector) setResponse(body []byte) {
+	var resp completionChunk
+	if err := json.Unmarshal(body, &resp); err != nil || len(resp.Choices) == 0 {
+		cc.raw.Write(body)
+		return
+	}
+	cc.add(&resp, false)
+}
+
+func (cc *captureCollector) add(chunk *completionChunk, stream bool) {
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		if stream {
+			cc.completion.WriteString(choice.Delta.Content)
+		} else {
+			cc.completion.WriteString(choice.Message.Content)
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			cc.finishReason = *choice.FinishReason
+		}
+	}
+	if chunk.Usage != nil {
+		cc.promptTokens = chunk.Usage.PromptTokens
+		cc.completionTokens = chunk.Usage.CompletionTokens
+	}
+}
+
+// content 回复内容，没有解析到候选时返回原始内容
+func (cc *captureCollector) content() string {
+	if cc.completion.Len() > 0 {
+		return cc.completion.String()
+	}
+	return cc.raw.String()
+}
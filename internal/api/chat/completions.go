@@ -4,12 +4,13 @@ package chat
 import (
 	"bufio"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"llmapisrv/internal/middleware"
+	"llmapisrv/internal/model"
 	"llmapisrv/internal/service"
 	"llmapisrv/pkg/logger"
 	"llmapisrv/pkg/queue"
@@ -19,93 +20,159 @@ import (
 )
 
 type ChatHandler struct {
-	newAPIService *service.NewAPIService
-	logService    *service.LogService
-	queue         *queue.RedisQueue
+	newAPIService        *service.NewAPIService
+	modelService         *service.ModelService
+	logService           *service.LogService
+	apiKeyService        *service.APIKeyService
+	spendingService      *service.SpendingService
+	captureService       *service.CaptureService
+	requestRecordService *service.RequestRecordService
+	queue                *queue.RedisQueue
 }
 
 func NewChatHandler(
 	newAPIService *service.NewAPIService,
+	modelService *service.ModelService,
 	logService *service.LogService,
+	apiKeyService *service.APIKeyService,
+	spendingService *service.SpendingService,
+	captureService *service.CaptureService,
+	requestRecordService *service.RequestRecordService,
 	queue *queue.RedisQueue,
 ) *ChatHandler {
 	return &ChatHandler{
-		newAPIService: newAPIService,
-		logService:    logService,
-		queue:         queue,
+		newAPIService:        newAPIService,
+		modelService:         modelService,
+		logService:           logService,
+		apiKeyService:        apiKeyService,
+		spendingService:      spendingService,
+		captureService:       captureService,
+		requestRecordService: requestRecordService,
+		queue:                queue,
 	}
 }
 
 // ChatCompletions 处理聊天完成请求
 func (h *ChatHandler) ChatCompletions(c *gin.Context) {
-	// 获取API Key
-	apiKey := c.GetHeader("Authorization")
+	// 获取所属用户的主密钥，子密钥统一使用主密钥调用上游
+	apiKey := c.GetString("api_key")
 	if apiKey == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key is required"})
 		return
 	}
+	apiKey = "sk-" + apiKey
+	apiKeyID := c.GetUint("api_key_id")
 
-	// 移除Bearer前缀
-	apiKey = util.ExtractToken(apiKey)
+	// 请求结束时写入网关调用记录，转发前被拒绝的请求同样记录
+	startTime := time.Now()
+	record := h.newRequestRecord(c)
+	var logData map[string]interface{}
+	var connectTime time.Duration
+	defer func() {
+		h.finishRequestRecord(c, record, startTime, connectTime, logData)
+	}()
 
 	// 读取请求体
 	var requestBody map[string]interface{}
 	if v, exists := c.Get("req"); exists {
 		requestBody = v.(map[string]interface{})
 	}
-	logger.Infof("ChatCompletions reqBody: %v", util.ToJSONString(requestBody))
+	logger.Infof("ChatCompletions model: %v, stream: %v", requestBody["model"], requestBody["stream"])
 
 	// 检查是否为流式响应
 	isStream, ok := requestBody["stream"].(bool)
 	if !ok {
 		isStream = false
 	}
+	record.Model, _ = requestBody["model"].(string)
+	record.IsStream = isStream
+
+	// 检查子密钥消费上限
+	if err := h.apiKeyService.CheckQuotaLimit(c.Request.Context(), apiKeyID); err != nil {
+		record.Status, record.Error = service.RequestStatusRejected, err.Error()
+		util.Fail(c, util.LimitErrorCode, err.Error())
+		return
+	}
+
+	// 检查每日、每月消费上限
+	if err := h.spendingService.Check(c.Request.Context(), c.GetUint("user_id"), apiKeyID); err != nil {
+		record.Status, record.Error = service.RequestStatusRejected, err.Error()
+		util.Fail(c, util.LimitErrorCode, err.Error())
+		return
+	}
+
+	// 密钥开启了内容采集时，在转发前保存客户端请求（转发时会替换模型名称）
+	capture := h.newCapture(c, requestBody, isStream)
 
 	// 转发请求
-	startTime := time.Now()
-	resp, err := h.newAPIService.ChatCompletion(apiKey, requestBody)
+	resp, call, err := h.newAPIService.ChatCompletionWithCall(c.Request.Context(), apiKey, requestBody)
+	record.UpstreamModel, record.Attempts = call.Model, call.Attempts
+	connectTime = call.ConnectTime
 	if err != nil {
+		record.Status, record.Error = service.RequestStatusFailed, err.Error()
 		logger.Infof("ChatCompletion got err: %v", err.Error())
 		util.ServerError(c, err)
 		return
 	}
 	defer resp.Body.Close()
+	record.UpstreamStatus = resp.StatusCode
+
+	var collector *captureCollector
+	if capture != nil {
+		collector = &captureCollector{}
+		defer func() {
+			capture.StatusCode = resp.StatusCode
+			capture.Duration = time.Since(startTime)
+			h.saveCapture(capture, collector)
+		}()
+	}
 
 	// 根据是否为流式响应选择不同的处理方式
+	var usage map[string]interface{}
 	if isStream {
 		// 处理流式响应
-		h.handleStreamResponse(c, resp, apiKey, requestBody)
+		usage = h.handleStreamResponse(c, resp, record, startTime, collector)
 	} else {
 		// 处理非流式响应
 		// 读取响应
 		body, err := io.ReadAll(resp.Body)
+		record.TTFT = time.Since(startTime).Milliseconds()
 		if err != nil {
+			record.Status, record.Error = service.RequestStatusFailed, err.Error()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		if collector != nil {
+			collector.setResponse(body)
+		}
 
 		var responseData map[string]interface{}
 		if err := json.Unmarshal(body, &responseData); err == nil {
 			// 提取使用情况
-			if usage, ok := responseData["usage"].(map[string]interface{}); ok {
-				// 发送到队列，异步记录日志
-				logData := map[string]interface{}{
-					"api_key":  strings.Replace(apiKey, "sk-", "", -1),
-					"model":    requestBody["model"],
-					"usage":    usage,
-					"duration": time.Since(startTime).Milliseconds(),
-				}
-				h.queue.Push("log:chat", logData)
-			}
+			usage, _ = responseData["usage"].(map[string]interface{})
 		}
 
 		// 返回原始响应
 		c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), body)
 	}
+
+	if usage != nil {
+		setRecordUsage(record, usage)
+		// 供 MetricsMiddleware 累加 token_usage_total
+		c.Set("token_usage", usage)
+		// 调用记录写入后发送到队列，异步同步日志并关联调用记录
+		logData = map[string]interface{}{
+			"api_key":  strings.Replace(apiKey, "sk-", "", -1),
+			"model":    requestBody["model"],
+			"usage":    usage,
+			"duration": time.Since(startTime).Milliseconds(),
+			"trace_id": record.TraceID,
+		}
+	}
 }
 
-// 流式响应处理
-func (h *ChatHandler) handleStreamResponse(c *gin.Context, resp *http.Response, apiKey string, requestBody map[string]interface{}) {
+// 流式响应处理，返回最后一块数据中的使用情况
+func (h *ChatHandler) handleStreamResponse(c *gin.Context, resp *http.Response, record *model.RequestRecord, startTime time.Time, collector *captureCollector) map[string]interface{} {
 	// 设置响应头
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
@@ -124,11 +191,22 @@ func (h *ChatHandler) handleStreamResponse(c *gin.Context, resp *http.Response,
 		c.Writer.Write(chunk)
 		c.Writer.Write([]byte("\n"))
 		c.Writer.Flush()
+		if record.TTFT == 0 {
+			record.TTFT = time.Since(startTime).Milliseconds()
+		}
 
 		// 保存最后一块数据
 		// lastChunk = chunk
 
-		chunkList = append(chunkList, chunk)
+		// 采集时逐块拼接回复内容，scanner 的缓冲区会被后续读取覆盖
+		if collector != nil {
+			collector.addChunk(chunk)
+		}
+
+		chunkList = append(chunkList, append([]byte(nil), chunk...))
+	}
+	if err := scanner.Err(); err != nil {
+		record.Error = err.Error()
 	}
 
 	var chunkData map[string]interface{}
@@ -137,7 +215,6 @@ func (h *ChatHandler) handleStreamResponse(c *gin.Context, resp *http.Response,
 		if len(chunkList[i]) < 6 {
 			continue
 		}
-		fmt.Println(string(chunkList[i][6:])) // 跳过开头的 data:
 		err := json.Unmarshal(chunkList[i][6:], &chunkData)
 		if err != nil {
 			continue
@@ -152,13 +229,117 @@ func (h *ChatHandler) handleStreamResponse(c *gin.Context, resp *http.Response,
 	// 处理最后一块数据，提取使用情况
 	if len(lastChunk) > 0 {
 		if usage, ok := chunkData["usage"].(map[string]interface{}); ok {
-			// 发送到队列，异步记录日志
-			logData := map[string]interface{}{
-				"api_key": strings.Replace(apiKey, "sk-", "", -1),
-				"model":   requestBody["model"],
-				"usage":   usage,
-			}
+			return usage
+		}
+	}
+	return nil
+}
+
+// newRequestRecord 根据鉴权和客户端信息创建调用记录
+func (h *ChatHandler) newRequestRecord(c *gin.Context) *model.RequestRecord {
+	record := &model.RequestRecord{
+		TraceID:   logger.TraceID(c.Request.Context()),
+		UserID:    c.GetUint("user_id"),
+		APIKeyID:  c.GetUint("api_key_id"),
+		ClientIP:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Referer:   c.Request.Referer(),
+		CreatedAt: time.Now(),
+	}
+	if v, exists := c.Get("client_info"); exists {
+		info := v.(*middleware.ClientInfo)
+		record.Browser = info.Browser
+		record.OS = info.OS
+		record.DeviceType = info.DeviceType
+	}
+	return record
+}
+
+// finishRequestRecord 补全状态和耗时后异步写入调用记录，写入后再发送日志同步消息
+func (h *ChatHandler) finishRequestRecord(c *gin.Context, record *model.RequestRecord, startTime time.Time, connectTime time.Duration, logData map[string]interface{}) {
+	record.Latency = time.Since(startTime).Milliseconds()
+	record.StatusCode = c.Writer.Status()
+	if record.Status == "" {
+		switch {
+		case record.IsStream && c.Request.Context().Err() != nil:
+			record.Status = service.RequestStatusCanceled
+		case record.UpstreamStatus >= http.StatusBadRequest:
+			record.Status = service.RequestStatusUpstreamError
+		case record.StatusCode >= http.StatusBadRequest || record.Error != "":
+			record.Status = service.RequestStatusFailed
+		default:
+			record.Status = service.RequestStatusSuccess
+		}
+	}
+	// 在异步写入前记录指标，避免与 Save 截断字段并发读写
+	observeChatMetrics(record, connectTime)
+
+	go func() {
+		if err := h.requestRecordService.Save(record); err != nil {
+			logger.Errorf("ChatCompletions save request record failed: %v", err)
+		}
+		h.chargeSubKey(record)
+		if logData != nil {
 			h.queue.Push("log:chat", logData)
 		}
+	}()
+}
+
+// chargeSubKey 按网关记录的 token 数计算额度，累加到子密钥已用额度和每日、每月消费
+// 按请求的子密钥直接计入，不依赖之后同步的 New API 日志匹配
+func (h *ChatHandler) chargeSubKey(record *model.RequestRecord) {
+	if record.APIKeyID == 0 || record.PromptTokens+record.CompletionTokens == 0 {
+		return
+	}
+	quota, err := h.modelService.CalculateQuota(record.Model, record.PromptTokens, record.CompletionTokens)
+	if err != nil {
+		logger.Errorf("ChatCompletions calculate sub key quota failed, api_key_id: %v, err: %v", record.APIKeyID, err)
+		return
 	}
+	if err := h.apiKeyService.RecordUsage(record.APIKeyID, quota); err != nil {
+		logger.Errorf("ChatCompletions record sub key usage failed, api_key_id: %v, err: %v", record.APIKeyID, err)
+	}
+	h.spendingService.RecordKey(record.APIKeyID, quota, record.CreatedAt)
+}
+
+// setRecordUsage 从响应的 usage 中读取token数
+func setRecordUsage(record *model.RequestRecord, usage map[string]interface{}) {
+	promptTokens, _ := usage["prompt_tokens"].(float64)
+	completionTokens, _ := usage["completion_tokens"].(float64)
+	totalTokens, _ := usage["total_tokens"].(float64)
+	record.PromptTokens = int(promptTokens)
+	record.CompletionTokens = int(completionTokens)
+	record.TotalTokens = int(totalTokens)
+}
+
+// newCapture 当前密钥开启内容采集时创建采集记录，否则返回 nil
+func (h *ChatHandler) newCapture(c *gin.Context, requestBody map[string]interface{}, isStream bool) *service.CaptureRecord {
+	v, exists := c.Get("auth_snapshot")
+	if !exists || !v.(*service.AuthSnapshot).CaptureActive() {
+		return nil
+	}
+
+	modelName, _ := requestBody["model"].(string)
+	return &service.CaptureRecord{
+		UserID:    c.GetUint("user_id"),
+		APIKeyID:  c.GetUint("api_key_id"),
+		TraceID:   logger.TraceID(c.Request.Context()),
+		ModelName: modelName,
+		IsStream:  isStream,
+		Request:   []byte(util.ToJSONString(requestBody)),
+	}
+}
+
+// saveCapture 异步保存采集内容，不影响响应
+func (h *ChatHandler) saveCapture(capture *service.CaptureRecord, collector *captureCollector) {
+	capture.Completion = collector.content()
+	capture.FinishReason = collector.finishReason
+	capture.PromptTokens = collector.promptTokens
+	capture.CompletionTokens = collector.completionTokens
+
+	go func() {
+		if err := h.captureService.Save(capture); err != nil {
+			logger.Errorf("ChatCompletions save capture failed: %v", err)
+		}
+	}()
 }
@@ -0,0 +1,84 @@
+// internal/api/chat/metrics.go
+package chat
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"llmapisrv/internal/model"
+	"llmapisrv/internal/service"
+)
+
+var (
+	// 获取上游连接的耗时
+	upstreamConnectSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "upstream_connect_seconds",
+			Help:    "Time to obtain a connection to the upstream in seconds",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+		},
+		[]string{"model", "upstream_model"},
+	)
+
+	// 流式响应首个分片的耗时
+	timeToFirstTokenSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "time_to_first_token_seconds",
+			Help:    "Time from request start to the first streamed chunk in seconds",
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20, 30, 60},
+		},
+		[]string{"model", "upstream_model"},
+	)
+
+	// 生成速度，流式按首个分片之后的耗时计算
+	tokensPerSecond = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "completion_tokens_per_second",
+			Help:    "Completion tokens generated per second",
+			Buckets: []float64{1, 5, 10, 20, 30, 50, 75, 100, 150, 200, 300},
+		},
+		[]string{"model", "upstream_model"},
+	)
+
+	// 流式响应总耗时
+	streamDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "stream_duration_seconds",
+			Help:    "Total duration of streamed responses in seconds",
+			Buckets: []float64{1, 2, 5, 10, 20, 30, 60, 120, 300, 600},
+		},
+		[]string{"model", "upstream_model"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(upstreamConnectSeconds)
+	prometheus.MustRegister(timeToFirstTokenSeconds)
+	prometheus.MustRegister(tokensPerSecond)
+	prometheus.MustRegister(streamDurationSeconds)
+}
+
+// observeChatMetrics 根据调用记录记录上游耗时指标，未转发到上游的请求不记录
+func observeChatMetrics(record *model.RequestRecord, connectTime time.Duration) {
+	if record.Attempts == 0 || record.UpstreamModel == "" {
+		return
+	}
+	labels := prometheus.Labels{"model": record.Model, "upstream_model": record.UpstreamModel}
+
+	upstreamConnectSeconds.With(labels).Observe(connectTime.Seconds())
+
+	// 生成耗时：流式为首个分片之后到结束，非流式为整个请求
+	generation := record.Latency
+	if record.IsStream {
+		if record.TTFT > 0 {
+			timeToFirstTokenSeconds.With(labels).Observe(float64(record.TTFT) / 1000)
+			generation = record.Latency - record.TTFT
+		}
+		streamDurationSeconds.With(labels).Observe(float64(record.Latency) / 1000)
+	}
+
+	if record.Status == service.RequestStatusSuccess && record.CompletionTokens > 0 && generation > 0 {
+		tokensPerSecond.With(labels).Observe(float64(record.CompletionTokens) / (float64(generation) / 1000))
+	}
+}
@@ -4,20 +4,23 @@ package dashboard
 import (
 	"llmapisrv/internal/service"
 	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/money"
 	"llmapisrv/pkg/util"
 
 	"github.com/gin-gonic/gin"
 )
 
 type BillingHandler struct {
-	newAPIService *service.NewAPIService
-	userService   *service.UserService
+	newAPIService   *service.NewAPIService
+	userService     *service.UserService
+	spendingService *service.SpendingService
 }
 
-func NewBillingHandler(newAPIService *service.NewAPIService, userService *service.UserService) *BillingHandler {
+func NewBillingHandler(newAPIService *service.NewAPIService, userService *service.UserService, spendingService *service.SpendingService) *BillingHandler {
 	return &BillingHandler{
-		newAPIService: newAPIService,
-		userService:   userService,
+		newAPIService:   newAPIService,
+		userService:     userService,
+		spendingService: spendingService,
 	}
 }
 
@@ -31,7 +34,8 @@ func NewBillingHandler(newAPIService *service.NewAPIService, userService *servic
         "has_payment_method": true,
         "object": "billing_subscription",
         "soft_limit_usd": 11,//总额度
-        "system_hard_limit_usd": 11//总额度
+        "system_hard_limit_usd": 11,//总额度
+        "hard_limit_v2": {"quota": 5500000, "usd": 11, "amount": 11, "currency": "USD", "symbol": "$"}
     }
 }
 */
@@ -46,6 +50,10 @@ func (h *BillingHandler) GetSubscription(c *gin.Context) {
 		return
 	}
 
+	if hardLimit, ok := billing["hard_limit_usd"].(float64); ok {
+		billing["hard_limit_v2"] = money.FromUSD(hardLimit)
+	}
+
 	util.Success(c, billing)
 }
 
@@ -55,7 +63,16 @@ func (h *BillingHandler) GetSubscription(c *gin.Context) {
     "message": "success",
     "data": {
         "object": "list",
-        "total_usage": 4928475 // 已使用的额度， 4928475/500000 后为美元额度
+        "total_usage": 985, // 旧版字段，已使用美元的100倍
+        "total_usage_v2": {"quota": 4928475, "usd": 9.85695, "amount": 9.85695, "currency": "USD", "symbol": "$"},
+        "remain_quota_v2": {...},
+        "spending": {
+            "user": {
+                "daily": {"limit": {...}, "spent": {...}, "reset_at": 1767369600},  // limit.quota 为0表示不限制
+                "monthly": {"limit": {...}, "spent": {...}, "reset_at": 1769875200}
+            },
+            "key": {...} // 子密钥调用时返回子密钥的消费情况
+        }
     }
 }
 */
@@ -73,9 +90,19 @@ func (h *BillingHandler) GetUsage(c *gin.Context) {
 
 	// 构建响应
 	response := map[string]interface{}{
-		"object":      "list",
-		"total_usage": user.UsedQuota * 100 / 500000, // 转换为美元（为了避免浮点数问题，先将其乘以100倍）
+		"object":          "list",
+		"total_usage":     user.UsedQuota * 100 / money.QuotaPerUSD(), // 旧版字段，美元乘以100，按 money.quota_per_usd 换算、不随展示货币变化，保留兼容
+		"total_usage_v2":  money.FromQuota(user.UsedQuota),
+		"remain_quota_v2": money.FromQuota(user.RemainQuota),
+	}
+
+	// 每日、每月消费及重置时间
+	spending, err := h.spendingService.GetStatus(userID, c.GetUint("api_key_id"))
+	if err != nil {
+		util.ServerError(c, err)
+		return
 	}
+	response["spending"] = spending
 
 	util.Success(c, response)
 }
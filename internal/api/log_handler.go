@@ -2,11 +2,15 @@
 package api
 
 import (
+	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"llmapisrv/internal/service"
+	"llmapisrv/pkg/export"
+	"llmapisrv/pkg/logger"
 	"llmapisrv/pkg/util"
 )
 
@@ -20,10 +24,21 @@ func NewLogHandler(logService *service.LogService) *LogHandler {
 	}
 }
 
-// GetLogs 获取日志
+// GetLogs 获取日志，携带 cursor 参数时按游标分页（首页传空值），否则按页码分页
 func (h *LogHandler) GetLogs(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
+	filter, err := service.ParseLogFilter(c.Request.URL.Query())
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	if cursorStr, ok := c.GetQuery("cursor"); ok {
+		h.getLogsByCursor(c, userID, filter, cursorStr)
+		return
+	}
+
 	// 获取分页参数
 	pageStr := c.DefaultQuery("page", "1")
 	pageSizeStr := c.DefaultQuery("page_size", "20")
@@ -39,7 +54,7 @@ func (h *LogHandler) GetLogs(c *gin.Context) {
 	}
 
 	// 获取日志
-	logs, total, err := h.logService.GetLogsByUserID(userID, page, pageSize)
+	logs, total, err := h.logService.GetLogsByUserID(userID, filter, page, pageSize)
 	if err != nil {
 		util.Fail(c, util.FailCode, err.Error())
 		return
@@ -82,6 +97,105 @@ func (h *LogHandler) GetLogs(c *gin.Context) {
 	util.Success(c, response)
 }
 
+// getLogsByCursor 游标分页，不统计总数，next_cursor 为空表示没有更多数据
+func (h *LogHandler) getLogsByCursor(c *gin.Context, userID uint, filter service.LogFilter, cursorStr string) {
+	cursor, err := service.DecodeLogCursor(cursorStr)
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	logs, next, err := h.logService.GetLogsByCursor(userID, filter, cursor, limit)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	nextCursor := ""
+	if next != nil {
+		nextCursor = next.Encode()
+	}
+
+	util.Success(c, gin.H{
+		"data": logs,
+		"meta": gin.H{
+			"limit":       limit,
+			"next_cursor": nextCursor,
+			"has_more":    next != nil,
+		},
+	})
+}
+
+/**
+{
+	"total": {"count": 120, "quota": 985000, "prompt_tokens": 35000, "completion_tokens": 82000, "amount": {...}},
+	"by_model": [{"key": "gpt-4o", "count": 80, "quota": 700000, ...}],
+	"by_day": [{"key": "2026-01-02", "count": 12, "quota": 98000, ...}]
+}
+*/
+// GetLogSummary 按模型和按天汇总日志，未指定时间范围时默认最近30天，tz 为按天分组的时区，默认服务器时区
+func (h *LogHandler) GetLogSummary(c *gin.Context) {
+	filter, err := service.ParseLogFilter(c.Request.URL.Query())
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "Local"))
+	if err != nil {
+		util.ParamError(c, "invalid tz")
+		return
+	}
+	if filter.StartTime == 0 {
+		end := filter.EndTime
+		if end == 0 {
+			end = time.Now().Unix()
+		}
+		filter.StartTime = end - 30*86400
+	}
+
+	summary, err := h.logService.GetLogSummary(c.GetUint("user_id"), filter, loc)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, summary)
+}
+
+// ExportLogs 导出当前用户的日志，format 为 csv 或 xlsx，tz 为时区名（如 Asia/Shanghai），默认服务器时区
+func (h *LogHandler) ExportLogs(c *gin.Context) {
+	filter, err := service.ParseLogFilter(c.Request.URL.Query())
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	format := c.DefaultQuery("format", export.FormatCSV)
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "Local"))
+	if err != nil {
+		util.ParamError(c, "invalid tz")
+		return
+	}
+
+	writer, err := export.NewRowWriter(format, c.Writer)
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	fileName := fmt.Sprintf("usage_%s.%s", time.Now().Format("20060102_150405"), format)
+	c.Header("Content-Type", export.ContentType(format))
+	c.Header("Content-Disposition", "attachment; filename="+fileName)
+	if err := h.logService.ExportLogs(c.GetUint("user_id"), filter, loc, writer); err != nil {
+		logger.ErrorWithCtx(c.Request.Context(), "Failed to export logs", err)
+	}
+}
+
 // CleanupOldLogs 清理旧日志（仅清理本地数据库）
 func (h *LogHandler) CleanupOldLogs(c *gin.Context) {
 	// 清理旧日志
@@ -0,0 +1,112 @@
+// internal/api/notification.go
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/internal/service"
+	"llmapisrv/pkg/money"
+	"llmapisrv/pkg/util"
+)
+
+type UpdateNotificationSettingsRequest struct {
+	WebhookURL        string    `json:"webhook_url" binding:"max=512"`
+	BalanceThresholds []float64 `json:"balance_thresholds"` // 余额阈值（美元）
+	ExpiryDays        []int     `json:"expiry_days"`        // 到期前提醒天数
+	Email             string    `json:"email" binding:"max=255"`
+	Language          string    `json:"language"` // 邮件语言：zh, en
+	Enabled           bool      `json:"enabled"`
+}
+
+type NotificationHandler struct {
+	notificationService *service.NotificationService
+}
+
+func NewNotificationHandler(notificationService *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+// GetSettings 获取通知设置
+func (h *NotificationHandler) GetSettings(c *gin.Context) {
+	setting, err := h.notificationService.GetSettings(c.GetUint("user_id"))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, service.NewNotificationSettingsView(setting))
+}
+
+// UpdateSettings 保存通知设置
+func (h *NotificationHandler) UpdateSettings(c *gin.Context) {
+	var req UpdateNotificationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	thresholds := make([]int64, 0, len(req.BalanceThresholds))
+	for _, usd := range req.BalanceThresholds {
+		thresholds = append(thresholds, money.USDToQuota(usd))
+	}
+
+	setting, err := h.notificationService.UpdateSettings(c.GetUint("user_id"), service.NotificationSettingsParams{
+		WebhookURL:        req.WebhookURL,
+		BalanceThresholds: thresholds,
+		ExpiryDays:        req.ExpiryDays,
+		Email:             req.Email,
+		Language:          req.Language,
+		Enabled:           req.Enabled,
+	})
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, service.NewNotificationSettingsView(setting))
+}
+
+// RotateSecret 重新生成签名密钥
+func (h *NotificationHandler) RotateSecret(c *gin.Context) {
+	setting, err := h.notificationService.RotateSecret(c.GetUint("user_id"))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, service.NewNotificationSettingsView(setting))
+}
+
+// SendTest 发送测试事件
+func (h *NotificationHandler) SendTest(c *gin.Context) {
+	if err := h.notificationService.SendTest(c.GetUint("user_id")); err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, "Test event queued")
+}
+
+// ListDeliveries 分页获取投递记录
+func (h *NotificationHandler) ListDeliveries(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	deliveries, total, err := h.notificationService.ListDeliveries(c.GetUint("user_id"), page, pageSize)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.PageSuccess(c, deliveries, total, page, pageSize)
+}
@@ -0,0 +1,94 @@
+// internal/api/order.go
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/internal/service"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/util"
+)
+
+type CreateOrderRequest struct {
+	Amount   int64  `json:"amount" binding:"required,min=1"` // 充值金额（单位：分）
+	Provider string `json:"provider"`                        // 支付渠道，为空使用默认渠道
+}
+
+type OrderHandler struct {
+	orderService *service.OrderService
+}
+
+func NewOrderHandler(orderService *service.OrderService) *OrderHandler {
+	return &OrderHandler{
+		orderService: orderService,
+	}
+}
+
+// CreateOrder 创建充值订单，返回支付地址或二维码内容
+func (h *OrderHandler) CreateOrder(c *gin.Context) {
+	var req CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	order, result, err := h.orderService.CreateOrder(c.Request.Context(), c.GetUint("user_id"), req.Amount, req.Provider)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, gin.H{
+		"order":   order,
+		"payment": result,
+	})
+}
+
+// GetOrder 查询订单状态
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	order, err := h.orderService.GetOrder(c.GetUint("user_id"), c.Param("order_no"))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, order)
+}
+
+// ListOrders 分页获取订单
+func (h *OrderHandler) ListOrders(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	orders, total, err := h.orderService.ListOrders(c.GetUint("user_id"), page, pageSize)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.PageSuccess(c, orders, total, page, pageSize)
+}
+
+// Notify 支付渠道异步通知，应答格式由渠道决定
+func (h *OrderHandler) Notify(c *gin.Context) {
+	provider, ok := h.orderService.Provider(c.Param("provider"))
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	err := h.orderService.HandleNotify(provider, c.Request)
+	if err != nil {
+		logger.Errorf("Payment notify from %s failed: %v", provider.Name(), err)
+	}
+	provider.AckNotify(c.Writer, err == nil)
+}
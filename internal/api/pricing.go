@@ -4,6 +4,7 @@ package api
 import (
 	"llmapisrv/internal/service"
 	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/money"
 	"llmapisrv/pkg/util"
 
 	"github.com/gin-gonic/gin"
@@ -71,3 +72,13 @@ func (h *PricingHandler) GetPricing(c *gin.Context) {
 
 	util.Success(c, pricing)
 }
+
+// GetCurrency 获取额度换算比例和展示货币，页面统一按此换算金额
+func (h *PricingHandler) GetCurrency(c *gin.Context) {
+	util.Success(c, gin.H{
+		"quota_per_usd":     money.QuotaPerUSD(),
+		"currency":          money.Currency(),
+		"symbol":            money.Symbol(),
+		"usd_exchange_rate": money.ExchangeRate(),
+	})
+}
@@ -44,7 +44,7 @@ func (h *ProxyHandler) ImageProxy(c *gin.Context) {
 	contentType := h.getContentType(path)
 	c.Header("Content-Type", contentType)
 	c.Header("Cache-Control", "public, max-age=86400") // 缓存1天
-	
+
 	// 返回图片数据
 	c.Data(http.StatusOK, contentType, imageData)
 }
@@ -65,4 +65,4 @@ func (h *ProxyHandler) getContentType(filename string) string {
 	default:
 		return "application/octet-stream"
 	}
-}
\ No newline at end of file
+}
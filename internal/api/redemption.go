@@ -2,13 +2,16 @@
 package api
 
 import (
-	"net/http"
+	"errors"
 
 	"llmapisrv/internal/middleware"
 	"llmapisrv/internal/service"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/money"
 	"llmapisrv/pkg/util"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 type RedemptionRequest struct {
@@ -19,24 +22,29 @@ type RedemptionHandler struct {
 	newAPIService     *service.NewAPIService
 	redemptionService *service.RedemptionService
 	userService       *service.UserService
+	redeemGuard       *service.RedeemGuardService
 }
 
 func NewRedemptionHandler(
 	newAPIService *service.NewAPIService,
-	redemptionService *service.RedemptionService, userService *service.UserService) *RedemptionHandler {
+	redemptionService *service.RedemptionService, userService *service.UserService,
+	redeemGuard *service.RedeemGuardService) *RedemptionHandler {
 	return &RedemptionHandler{
 		newAPIService:     newAPIService,
 		redemptionService: redemptionService,
 		userService:       userService,
+		redeemGuard:       redeemGuard,
 	}
 }
 
+// 锁定期间统一提示，不区分兑换码是否存在
+const redeemLockedMessage = "尝试次数过多，请稍后再试"
+
 // RedeemCode 兑换码兑换
 func (h *RedemptionHandler) RedeemCode(c *gin.Context) {
 	var req RedemptionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		util.ParamError(c, err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	clientInfoInterface, exists := c.Get("client_info")
@@ -48,22 +56,25 @@ func (h *RedemptionHandler) RedeemCode(c *gin.Context) {
 
 	userID := c.GetUint("user_id")
 
-	// 兑换码验证与使用
-	quota, err := h.redemptionService.RedeemCode(req.Code, userID)
-	if err != nil {
-		util.Fail(c, util.FailCode, err.Error())
+	if h.redeemGuard.IsLocked(service.RedeemEndpointRedeem, userID, clientInfo.IP) {
+		util.Fail(c, util.LimitErrorCode, redeemLockedMessage)
 		return
 	}
 
-	// 更新用户额度
-	if err := h.userService.AddQuota(userID, quota); err != nil {
-		util.Fail(c, util.FailCode, "Failed to add quota")
+	// 兑换码验证与使用，额度在同一事务中到账
+	detail, err := h.redemptionService.RedeemCode(req.Code, userID)
+	if err != nil {
+		h.handleRedeemError(c, err, service.RedeemEndpointRedeem, userID, clientInfo.IP)
 		return
 	}
+	h.redeemGuard.Reset(service.RedeemEndpointRedeem, userID)
 	h.newAPIService.GetBillingInfo(clientInfo.AuthNoSk, false)
 	util.Success(c, gin.H{
-		"quota":  quota,
-		"amount": int(float64(quota) / 500000), // 转换为美元显示
+		"type":      detail.Type,
+		"quota":     detail.Quota,
+		"amount":    int(detail.Quota / money.QuotaPerUSD()), // 旧版字段，取整后的美元，按 money.quota_per_usd 换算、不随展示货币变化，保留兼容
+		"amount_v2": money.FromQuota(detail.Quota),
+		"effect":    detail.Effect,
 	})
 }
 
@@ -74,17 +85,43 @@ func (h *RedemptionHandler) RedeemCodeInfo(c *gin.Context) {
 		util.ParamError(c, "code is required")
 		return
 	}
+	clientInfoInterface, exists := c.Get("client_info")
+	if !exists {
+		util.Fail(c, util.FailCode, "client_info is not exists")
+		return
+	}
+	clientInfo := clientInfoInterface.(*middleware.ClientInfo)
+
 	userID := c.GetUint("user_id")
 
+	if h.redeemGuard.IsLocked(service.RedeemEndpointInfo, userID, clientInfo.IP) {
+		util.Fail(c, util.LimitErrorCode, redeemLockedMessage)
+		return
+	}
+
 	// 兑换码验证与使用
-	quota, err := h.redemptionService.RedeemCodeInfo(code, userID)
+	detail, err := h.redemptionService.RedeemCodeInfo(code, userID)
 	if err != nil {
-		util.Fail(c, util.FailCode, err.Error())
+		h.handleRedeemError(c, err, service.RedeemEndpointInfo, userID, clientInfo.IP)
 		return
 	}
 
 	util.Success(c, gin.H{
-		"quota":  quota,
-		"amount": int(float64(quota) / 500000), // 转换为美元显示
+		"type":      detail.Type,
+		"quota":     detail.Quota,
+		"amount":    int(detail.Quota / money.QuotaPerUSD()), // 旧版字段，取整后的美元，按 money.quota_per_usd 换算、不随展示货币变化，保留兼容
+		"amount_v2": money.FromQuota(detail.Quota),
+		"effect":    detail.Effect,
 	})
 }
+
+// handleRedeemError 兑换码不可用时记录失败次数，其他错误只记录日志，对外统一提示
+func (h *RedemptionHandler) handleRedeemError(c *gin.Context, err error, endpoint string, userID uint, ip string) {
+	var codeErr *service.RedeemCodeError
+	if errors.As(err, &codeErr) {
+		h.redeemGuard.RecordFailure(endpoint, userID, ip, codeErr.Reason)
+	} else {
+		logger.ErrorWithCtx(c.Request.Context(), "Redeem code failed", err, zap.String("endpoint", endpoint))
+	}
+	util.Fail(c, util.FailCode, service.RedeemCodeInvalidMessage)
+}
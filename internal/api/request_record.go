@@ -0,0 +1,48 @@
+// internal/api/request_record.go
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/internal/service"
+	"llmapisrv/pkg/util"
+)
+
+type RequestRecordHandler struct {
+	recordService *service.RequestRecordService
+}
+
+func NewRequestRecordHandler(recordService *service.RequestRecordService) *RequestRecordHandler {
+	return &RequestRecordHandler{
+		recordService: recordService,
+	}
+}
+
+// ListRecords 分页查询网关调用记录，包含上游没有日志的失败请求
+func (h *RequestRecordHandler) ListRecords(c *gin.Context) {
+	filter, err := service.ParseRequestRecordFilter(c.Request.URL.Query())
+	if err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+	filter.UserID = c.GetUint("user_id")
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	records, total, err := h.recordService.ListRecords(filter, page, pageSize)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.PageSuccess(c, records, total, page, pageSize)
+}
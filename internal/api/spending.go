@@ -0,0 +1,85 @@
+// internal/api/spending.go
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/internal/service"
+	"llmapisrv/pkg/money"
+	"llmapisrv/pkg/util"
+)
+
+type UpdateSpendingLimitsRequest struct {
+	DailyLimit   float64 `json:"daily_limit" binding:"min=0"`   // 每日消费上限（美元），0为不限制
+	MonthlyLimit float64 `json:"monthly_limit" binding:"min=0"` // 每月消费上限（美元），0为不限制
+}
+
+type SpendingHandler struct {
+	spendingService *service.SpendingService
+}
+
+func NewSpendingHandler(spendingService *service.SpendingService) *SpendingHandler {
+	return &SpendingHandler{
+		spendingService: spendingService,
+	}
+}
+
+// GetSpending 获取每日、每月消费情况
+func (h *SpendingHandler) GetSpending(c *gin.Context) {
+	status, err := h.spendingService.GetStatus(c.GetUint("user_id"), c.GetUint("api_key_id"))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, status)
+}
+
+// UpdateUserLimits 设置账户的消费上限，对主密钥和所有子密钥合计生效
+func (h *SpendingHandler) UpdateUserLimits(c *gin.Context) {
+	var req UpdateSpendingLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	if err := h.spendingService.SetUserLimits(userID, money.USDToQuota(req.DailyLimit), money.USDToQuota(req.MonthlyLimit)); err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	status, err := h.spendingService.GetStatus(userID, 0)
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	util.Success(c, status)
+}
+
+// UpdateKeyLimits 设置子密钥的消费上限
+func (h *SpendingHandler) UpdateKeyLimits(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		util.ParamError(c, "invalid id")
+		return
+	}
+
+	var req UpdateSpendingLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.ParamError(c, err.Error())
+		return
+	}
+
+	apiKey, err := h.spendingService.SetKeyLimits(c.GetUint("user_id"), uint(id), money.USDToQuota(req.DailyLimit), money.USDToQuota(req.MonthlyLimit))
+	if err != nil {
+		util.Fail(c, util.FailCode, err.Error())
+		return
+	}
+
+	apiKey.Key = maskKey(apiKey.KeyPrefix)
+	util.Success(c, apiKey)
+}
@@ -2,6 +2,8 @@
 package api
 
 import (
+	"net/http"
+
 	"llmapisrv/internal/service"
 	"llmapisrv/pkg/util"
 
@@ -10,23 +12,33 @@ import (
 
 type StatusHandler struct {
 	newAPIService *service.NewAPIService
+	healthService *service.HealthService
 }
 
-func NewStatusHandler(newAPIService *service.NewAPIService) *StatusHandler {
+func NewStatusHandler(newAPIService *service.NewAPIService, healthService *service.HealthService) *StatusHandler {
 	return &StatusHandler{
 		newAPIService: newAPIService,
+		healthService: healthService,
 	}
 }
 
-// HealthCheck 健康检查
+// HealthCheck 状态页使用的健康检查，只返回各依赖是否正常，不含错误信息
 func (h *StatusHandler) HealthCheck(c *gin.Context) {
-	// 调用上游API的健康检查
-	// err := h.newAPIService.CheckHealth()
-	err := error(nil)
-	if err != nil {
-		util.Fail(c, util.FailCode, err.Error())
-		return
-	}
+	report := h.healthService.Check(c.Request.Context())
+	util.Success(c, report.Public())
+}
 
-	util.Success(c, "")
+// Liveness 存活探针，进程能处理请求即返回成功，不检查依赖
+func (h *StatusHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": service.HealthStatusOK})
+}
+
+// Readiness 就绪探针，只返回各依赖的名称和状态，必需依赖异常时返回 503，错误详情只写入日志
+func (h *StatusHandler) Readiness(c *gin.Context) {
+	report := h.healthService.Check(c.Request.Context())
+	status := http.StatusOK
+	if report.Status == service.HealthStatusFail {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report.Probe())
 }
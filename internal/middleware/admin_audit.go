@@ -0,0 +1,131 @@
+// internal/middleware/admin_audit.go
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/internal/model"
+	"llmapisrv/internal/service"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/util"
+)
+
+// 审计日志中参数和响应信息的最大长度
+const (
+	auditParamsMaxLen  = 4096
+	auditMessageMaxLen = 512
+	auditBodyMaxLen    = 64 * 1024
+)
+
+// AdminAuditMiddleware 管理员操作审计中间件，记录每一次 /api/admin 调用
+func AdminAuditMiddleware(auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		// 只读取JSON请求体的前 auditBodyMaxLen 字节，文件上传等其他请求体不缓存
+		var requestBody []byte
+		if c.Request.Body != nil && c.ContentType() == gin.MIMEJSON {
+			requestBody, _ = io.ReadAll(io.LimitReader(c.Request.Body, auditBodyMaxLen+1))
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(requestBody), c.Request.Body), c.Request.Body}
+		}
+
+		// 记录响应，用于判断操作结果
+		blw := &auditBodyWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer}
+		c.Writer = blw
+
+		c.Next()
+
+		action := c.Request.Method + " " + c.FullPath()
+		if c.FullPath() == "" {
+			action = c.Request.Method + " " + c.Request.URL.Path
+		}
+
+		params := auditParams(c, requestBody)
+		if c.Request.URL.RawQuery != "" {
+			params = util.ToJSONString(gin.H{"query": logger.RedactQuery(c.Request.URL.RawQuery), "body": params})
+		}
+
+		entry := &model.AdminAuditLog{
+			Action:    action,
+			Params:    params,
+			Status:    c.Writer.Status(),
+			IP:        c.ClientIP(),
+			TraceID:   logger.TraceID(c.Request.Context()),
+			LatencyMs: time.Since(start).Milliseconds(),
+			CreatedAt: start,
+		}
+		if v, exists := c.Get("client_info"); exists {
+			entry.IP = v.(*ClientInfo).IP
+		}
+		if v, exists := c.Get("admin"); exists {
+			admin := v.(*model.Admin)
+			entry.AdminID = admin.ID
+			entry.AdminName = admin.Name
+			entry.Role = admin.Role
+		}
+		entry.Result, entry.Message = auditResult(c.Writer.Status(), blw.body.Bytes())
+
+		if err := auditService.Record(entry); err != nil {
+			logger.ErrorWithCtx(c.Request.Context(), "Failed to write admin audit log", err)
+		}
+	}
+}
+
+// auditParams 脱敏后的请求参数，非JSON或过大的请求体只记录类型和长度
+func auditParams(c *gin.Context, requestBody []byte) string {
+	switch {
+	case len(requestBody) > auditBodyMaxLen:
+		return fmt.Sprintf("[json body over %d bytes]", auditBodyMaxLen)
+	case len(requestBody) > 0:
+		return logger.RedactBody(requestBody, auditParamsMaxLen)
+	case c.ContentType() != gin.MIMEJSON && c.Request.ContentLength > 0:
+		return fmt.Sprintf("[%s body, %d bytes]", c.ContentType(), c.Request.ContentLength)
+	default:
+		return ""
+	}
+}
+
+// auditResult 根据统一响应结构判断操作结果
+func auditResult(status int, body []byte) (string, string) {
+	result := "fail"
+	if status >= 400 {
+		return result, ""
+	}
+
+	var resp util.Response
+	if len(body) >= auditBodyMaxLen || json.Unmarshal(body, &resp) != nil {
+		// 非JSON或过大的响应（如CSV导出），以HTTP状态码为准
+		return "success", ""
+	}
+
+	if resp.Code == util.SuccessCode {
+		result = "success"
+	}
+	message := resp.Message
+	if len(message) > auditMessageMaxLen {
+		message = message[:auditMessageMaxLen]
+	}
+	return result, message
+}
+
+// auditBodyWriter 只缓存响应体的前 auditBodyMaxLen 字节
+type auditBodyWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w auditBodyWriter) Write(b []byte) (int, error) {
+	if remain := auditBodyMaxLen - w.body.Len(); remain > 0 {
+		w.body.Write(b[:min(len(b), remain)])
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -2,19 +2,22 @@
 package middleware
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 
-	"llmapisrv/config"
+	"llmapisrv/internal/model"
+	"llmapisrv/internal/service"
 	"llmapisrv/pkg/util"
 )
 
 // AdminAuthMiddleware 管理员认证中间件
-func AdminAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+func AdminAuthMiddleware(adminService *service.AdminService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 获取API Key
+		// 获取管理员token
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			util.Fail(c, util.UnauthorizedCode, "API key is required")
+			util.Fail(c, util.UnauthorizedCode, "Admin token is required")
 			c.Abort()
 			return
 		}
@@ -22,13 +25,38 @@ func AdminAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 		// 提取token
 		token := util.ExtractToken(authHeader)
 
-		// 检查是否是管理员key
-		if token != cfg.NewAPI.AdminKey {
+		// 校验管理员token
+		admin, err := adminService.Authenticate(token)
+		if err != nil {
 			util.Fail(c, util.UnauthorizedCode, "Requires administrator privileges")
 			c.Abort()
 			return
 		}
 
+		// 初始超级管理员只能创建管理员账号
+		if service.IsBootstrapAdmin(admin) && !(c.Request.Method == http.MethodPost && c.FullPath() == "/api/admin/admins") {
+			util.Fail(c, util.ForbiddenCode, "Bootstrap token can only create administrators")
+			c.Abort()
+			return
+		}
+
+		c.Set("admin", admin)
+		c.Set("admin_name", admin.Name)
+
+		c.Next()
+	}
+}
+
+// RequireAdminRole 校验管理员角色，超级管理员拥有全部权限
+func RequireAdminRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, exists := c.Get("admin")
+		if !exists || !service.HasRole(v.(*model.Admin), roles...) {
+			util.Fail(c, util.ForbiddenCode, "Insufficient administrator role")
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
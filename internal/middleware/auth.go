@@ -2,18 +2,19 @@
 package middleware
 
 import (
-	"strconv"
-	"time"
+	"context"
+	"errors"
 
 	"llmapisrv/internal/service"
-	"llmapisrv/pkg/cache"
-	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/tracing"
 	"llmapisrv/pkg/util"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func AuthMiddleware(userService *service.UserService, cache *cache.RedisCache) gin.HandlerFunc {
+func AuthMiddleware(apiKeyService *service.APIKeyService, authCache *service.AuthCacheService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 跳过对健康检查的认证
 		if c.Request.URL.Path == "/api/about" {
@@ -38,59 +39,118 @@ func AuthMiddleware(userService *service.UserService, cache *cache.RedisCache) g
 			return
 		}
 
-		// 提取token
-		token := clientInfo.AuthNoSk
-
-		// 检查缓存
-		cacheKey := "auth:" + token
-		if v, err := cache.Get(cacheKey); err == nil {
-			// 缓存命中，继续请求
-			userId, err := strconv.ParseUint(v, 10, 64)
-			logger.Infof("in AuthMiddleware: value: %v, user id: %v", v, userId)
-			if err == nil {
-				c.Set("user_id", uint(userId))
-				c.Set("api_key", token)
-				c.Next()
-				return
-			}
+		// 鉴权单独记录 span，在进入后续处理前结束
+		ctx, span := tracing.Start(c.Request.Context(), "auth")
+		snapshot, code, err := authenticate(ctx, apiKeyService, authCache, clientInfo)
+		if snapshot != nil {
+			span.SetAttributes(
+				attribute.Int64("user.id", int64(snapshot.UserID)),
+				attribute.Int64("api_key.id", int64(snapshot.APIKeyID)),
+			)
 		}
-
-		// 缓存未命中，查询数据库
-		user, err := userService.GetUserByAPIKey(token)
+		tracing.End(span, err)
 		if err != nil {
-			util.Fail(c, util.UnauthorizedCode, "Invalid API key")
+			util.Fail(c, code, err.Error())
 			c.Abort()
 			return
 		}
 
-		// 检查用户状态
-		if user.Status != 1 {
-			util.Fail(c, util.UnauthorizedCode, "API key is disabled")
-			c.Abort()
-			return
+		// 将用户信息添加到上下文
+		setAuthContext(c, snapshot)
+
+		c.Next()
+	}
+}
+
+// authenticate 校验请求携带的key，失败时返回错误码
+func authenticate(ctx context.Context, apiKeyService *service.APIKeyService, authCache *service.AuthCacheService, clientInfo *ClientInfo) (*service.AuthSnapshot, int, error) {
+	// 提取token
+	token := clientInfo.AuthNoSk
+
+	// 检查缓存，命中后仍需重新校验状态与过期时间
+	snapshot, cached := authCache.Get(ctx, token)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("auth.cache_hit", cached))
+	if !cached {
+		// 缓存未命中，查询数据库（子密钥解析到所属用户）
+		var err error
+		snapshot, err = apiKeyService.ResolveAuthSnapshot(ctx, token)
+		if err != nil {
+			return nil, util.UnauthorizedCode, errors.New("Invalid API key")
 		}
+	}
+
+	// 检查余额
+	// if user.RemainQuota <= 0 {
+	// 	return nil, util.UnauthorizedCode, errors.New("Insufficient quota")
+	// }
+
+	// 检查用户状态和过期时间
+	if err := snapshot.Validate(); err != nil {
+		return nil, util.UnauthorizedCode, err
+	}
 
-		// 检查余额
-		// if user.RemainQuota <= 0 {
-		// 	util.Fail(c, util.UnauthorizedCode, "Insufficient quota")
-		// 	c.Abort()
-		// 	return
-		// }
+	// 检查子密钥IP白名单
+	if !util.IPAllowed(clientInfo.IP, snapshot.AllowedIPs) {
+		return nil, util.ForbiddenCode, errors.New("IP address not allowed for this API key")
+	}
 
-		// 检查过期时间
-		if user.ExpiredTime > 0 && user.ExpiredTime < time.Now().Unix() {
-			util.Fail(c, util.UnauthorizedCode, "API key has expired")
+	// 更新缓存
+	if !cached {
+		authCache.Set(ctx, token, snapshot)
+	}
+	return snapshot, 0, nil
+}
+
+// setAuthContext 将鉴权信息写入上下文，api_key 始终为所属用户的主密钥
+func setAuthContext(c *gin.Context, snapshot *service.AuthSnapshot) {
+	c.Set("user_id", snapshot.UserID)
+	c.Set("api_key", snapshot.APIKey)
+	c.Set("api_key_id", snapshot.APIKeyID)
+	c.Set("tier", snapshot.CurrentTier())
+	c.Set("auth_snapshot", snapshot)
+}
+
+// RequireScope 校验子密钥是否拥有指定权限
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, exists := c.Get("auth_snapshot")
+		if !exists || !v.(*service.AuthSnapshot).HasScope(scope) {
+			util.Fail(c, util.ForbiddenCode, "API key does not have scope: "+scope)
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		// 将用户信息添加到上下文
-		c.Set("user_id", user.ID)
-		c.Set("api_key", token)
+// RequirePrimaryKey 仅允许主密钥访问（如兑换、子密钥管理等账户级操作）
+func RequirePrimaryKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetUint("api_key_id") != 0 {
+			util.Fail(c, util.ForbiddenCode, "This operation requires the primary API key")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
 
-		// 更新缓存，设置两小时过期
-		cache.Set(cacheKey, strconv.FormatInt(int64(user.ID), 10), 2*3600)
+// RequireModelAccess 校验请求的模型是否已解锁，未配置为需解锁的模型不受限制
+func RequireModelAccess(lockedModels []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var modelName string
+		if v, exists := c.Get("req"); exists {
+			if body, ok := v.(map[string]interface{}); ok {
+				modelName, _ = body["model"].(string)
+			}
+		}
 
+		v, exists := c.Get("auth_snapshot")
+		if !exists || !v.(*service.AuthSnapshot).CanUseModel(modelName, lockedModels) {
+			util.Fail(c, util.ForbiddenCode, "Model is not unlocked for this account: "+modelName)
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
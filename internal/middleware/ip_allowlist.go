@@ -0,0 +1,27 @@
+// internal/middleware/ip_allowlist.go
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"llmapisrv/pkg/util"
+)
+
+// IPAllowlistMiddleware IP白名单中间件，白名单为空时不限制
+// 客户端IP由 ClientInfoMiddleware 通过 c.ClientIP() 获取，只有可信代理的转发头才会被采信
+func IPAllowlistMiddleware(allowList []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		if v, exists := c.Get("client_info"); exists {
+			ip = v.(*ClientInfo).IP
+		}
+
+		if !util.IPAllowed(ip, allowList) {
+			util.Fail(c, util.ForbiddenCode, "IP address not allowed")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -15,6 +15,11 @@ import (
 
 // RateLimiterMiddleware 限流中间件
 func RateLimiterMiddleware(cache *cache.RedisCache, limit int, window int) gin.HandlerFunc {
+	return rateLimiter(cache, "", limit, window)
+}
+
+// rateLimiter 按用户和接口路径计数，scope 不为空时加入缓存键，与同一路径上的其他限流分开计数
+func rateLimiter(cache *cache.RedisCache, scope string, limit int, window int) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 获取用户标识
 		userID, exists := c.Get("user_id")
@@ -30,6 +35,9 @@ func RateLimiterMiddleware(cache *cache.RedisCache, limit int, window int) gin.H
 			endpoint = c.Request.URL.Path
 		}
 
+		if scope != "" {
+			endpoint = scope + ":" + endpoint
+		}
 		key := "rate_limit:" + endpoint + ":" + strconv.Itoa(int(userID.(uint)))
 
 		// 获取当前计数
@@ -76,3 +84,12 @@ func BillingRateLimiter(cache *cache.RedisCache, cfg *config.Config) gin.Handler
 func LogRateLimiter(cache *cache.RedisCache, cfg *config.Config) gin.HandlerFunc {
 	return RateLimiterMiddleware(cache, cfg.RateLimit.LogQueryLimit, 60) // 每分钟限制
 }
+
+// RedeemInfoRateLimiter 兑换码查询限流，阈值较低以防探测兑换码
+func RedeemInfoRateLimiter(cache *cache.RedisCache, cfg *config.Config) gin.HandlerFunc {
+	limit := cfg.RateLimit.RedeemInfoLimit
+	if limit <= 0 {
+		limit = 5
+	}
+	return rateLimiter(cache, "redeem_info", limit, 60) // 每分钟限制
+}
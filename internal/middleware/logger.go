@@ -11,8 +11,12 @@ import (
 	"llmapisrv/pkg/logger"
 )
 
-// Logger 日志中间件
-func LoggerMiddleware() gin.HandlerFunc {
+// 开启 log_bodies 时请求体最多记录的字节数
+const maxLoggedBodyBytes = 2048
+
+// Logger 日志中间件，默认不记录请求体和响应体，只记录大小
+// logBodies 为 true 时额外记录脱敏后的请求体，响应体始终不记录
+func LoggerMiddleware(logBodies bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 		start := time.Now()
@@ -21,68 +25,56 @@ func LoggerMiddleware() gin.HandlerFunc {
 
 		// 读取请求体
 		var requestBody []byte
-		if c.Request.Body != nil {
-			requestBody, _ = io.ReadAll(c.Request.Body)                 // Use io.ReadAll
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody)) // Use io.NopCloser
+		if logBodies && c.Request.Body != nil {
+			requestBody, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 		}
 
-		// 使用自定义ResponseWriter记录响应
-		blw := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer}
-		c.Writer = blw
-
 		// 处理请求
 		c.Next()
 
 		// 计算延迟
 		latency := time.Since(start)
 
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("path", path),
+			zap.Any("query", logger.RedactQuery(query)),
+			zap.Int("status", c.Writer.Status()),
+			zap.Duration("latency", latency),
+			zap.Int64("request-size", c.Request.ContentLength),
+			zap.Int("response-size", c.Writer.Size()),
+		}
+
 		// 获取客户端信息
 		clientInfo, exists := c.Get("client_info")
 		if exists {
 			info := clientInfo.(*ClientInfo)
 			// 使用客户端信息
-			logger.InfoWithCtx(ctx, "Request",
-				zap.String("method", c.Request.Method),
-				zap.String("path", path),
-				zap.String("query", query),
+			fields = append(fields,
 				zap.String("ip", info.RealIP),
 				zap.String("user-agent", info.UserAgent),
-				zap.Int("status", c.Writer.Status()),
-				zap.Duration("latency", latency),
 				zap.String("browser", info.Browser),
 				zap.String("forwarded-for", info.ForwardedFor),
 				zap.String("os", info.OS),
 				zap.String("device-type", info.DeviceType),
 				zap.String("referer", info.Referer),
-				zap.String("Authorization", info.Authorization),
-				zap.String("request", string(requestBody[:min(len(requestBody), 2048)])),      // 只输出前2048个字符
-				zap.String("response", blw.body.String()[:min(len(blw.body.String()), 2048)]), // 只输出前2048个字符
 			)
 		} else {
-			// 记录日志
-			logger.InfoWithCtx(ctx, "Request",
-				zap.String("method", c.Request.Method),
-				zap.String("path", path),
-				zap.String("query", query),
+			fields = append(fields,
 				zap.String("ip", c.ClientIP()),
 				zap.String("user-agent", c.Request.UserAgent()),
-				zap.Int("status", c.Writer.Status()),
-				zap.Duration("latency", latency),
-				zap.String("request", string(requestBody[:min(len(requestBody), 2048)])),      // 只输出前2048个字符
-				zap.String("response", blw.body.String()[:min(len(blw.body.String()), 2048)]), // 只输出前2048个字符
 			)
 		}
-	}
-}
 
-// bodyLogWriter 自定义ResponseWriter，用于记录响应体
-type bodyLogWriter struct {
-	gin.ResponseWriter
-	body *bytes.Buffer
-}
+		// 请求头按 logger.redaction 的允许/禁止列表过滤，授权头只保留首尾几位
+		fields = append(fields, logger.Headers("headers", c.Request.Header))
 
-// Write 重写Write方法，同时写入原ResponseWriter和缓冲区
-func (w bodyLogWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
-	return w.ResponseWriter.Write(b)
+		if logBodies {
+			fields = append(fields, zap.String("request", logger.RedactBody(requestBody, maxLoggedBodyBytes)))
+		}
+
+		// 记录日志
+		logger.InfoWithCtx(ctx, "Request", fields...)
+	}
 }
@@ -2,18 +2,55 @@
 package middleware
 
 import (
-	"context"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/tracing"
 )
 
-// TraceID generates a new trace ID and adds it to the gin context.
+// TraceIDMiddleware 读取 W3C traceparent 并创建请求 span，trace id 写入上下文并通过 X-Request-Id 返回
 func TraceIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		traceID := uuid.New().String()
-		ctx := context.WithValue(c.Request.Context(), "trace_id", traceID)
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+			),
+		)
+		defer span.End()
+
+		// 未初始化追踪时 span 无效，退回到随机生成
+		traceID := tracing.TraceID(ctx)
+		if traceID == "" {
+			traceID = strings.ReplaceAll(uuid.New().String(), "-", "")
+		}
+		ctx = logger.WithTraceID(ctx, traceID)
 		c.Request = c.Request.WithContext(ctx)
+		c.Header("X-Request-Id", traceID)
+
 		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, "")
+		}
+		if userID := c.GetUint("user_id"); userID > 0 {
+			span.SetAttributes(attribute.Int64("user.id", int64(userID)))
+		}
 	}
 }
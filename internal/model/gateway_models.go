@@ -2,34 +2,44 @@
 package model
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
+
+	"llmapisrv/pkg/util"
 )
 
 // 调用层数据库中的用户表
 type User struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	APIKey      string    `gorm:"column:api_key;uniqueIndex" json:"api_key"`
-	TokenID     uint      `gorm:"column:token_id" json:"token_id"`
-	RemainQuota int64     `gorm:"column:remain_quota" json:"remain_quota"` // 剩余额度（单位：0.001美元）
-	UsedQuota   int64     `gorm:"column:used_quota" json:"used_quota"`     // 已用额度
-	ExpiredTime int64     `gorm:"column:expired_time" json:"expired_time"` // 过期时间戳
-	Status      int       `gorm:"column:status" json:"status"`             // 状态：1正常，0禁用
-	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt   time.Time `gorm:"column:updated_at" json:"updated_at"`
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	APIKey          string    `gorm:"column:api_key;uniqueIndex" json:"api_key"`
+	TokenID         uint      `gorm:"column:token_id" json:"token_id"`
+	RemainQuota     int64     `gorm:"column:remain_quota" json:"remain_quota"`           // 剩余额度，按 money.quota_per_usd 换算为美元
+	UsedQuota       int64     `gorm:"column:used_quota" json:"used_quota"`               // 已用额度
+	ExpiredTime     int64     `gorm:"column:expired_time" json:"expired_time"`           // 过期时间戳
+	Status          int       `gorm:"column:status" json:"status"`                       // 状态：1正常，0禁用
+	Tier            string    `gorm:"column:tier;default:default" json:"tier"`           // 账户等级
+	TierExpiredTime int64     `gorm:"column:tier_expired_time" json:"tier_expired_time"` // 等级到期时间戳，0为长期有效
+	DailyLimit      int64     `gorm:"column:daily_limit" json:"daily_limit"`             // 每日消费上限（额度），0为不限制
+	MonthlyLimit    int64     `gorm:"column:monthly_limit" json:"monthly_limit"`         // 每月消费上限（额度），0为不限制
+	CaptureUntil    int64     `gorm:"column:capture_until" json:"capture_until"`         // 主密钥内容采集截止时间戳，0为未开启
+	CreatedAt       time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt       time.Time `gorm:"column:updated_at" json:"updated_at"`
 }
 
 // 调用层数据库中的日志表
 type Log struct {
 	ID                uint   `gorm:"primaryKey" json:"id"`
-	UserID            uint   `gorm:"column:user_id;index" json:"user_id"`
+	UserID            uint   `gorm:"column:user_id;index;index:idx_logs_user_created,priority:1;index:idx_logs_user_type_created,priority:1" json:"user_id"`
 	RemoteLogID       uint   `gorm:"column:remote_log_id;uniqueIndex" json:"remote_log_id"` // New API 中的日志ID
-	CreatedAt         int64  `gorm:"column:created_at" json:"created_at"`
-	Type              int    `gorm:"column:type" json:"type"`
+	CreatedAt         int64  `gorm:"column:created_at;index:idx_logs_created;index:idx_logs_user_created,priority:2;index:idx_logs_user_type_created,priority:3" json:"created_at"`
+	Type              int    `gorm:"column:type;index:idx_logs_user_type_created,priority:2" json:"type"`
 	Content           string `gorm:"column:content;type:text" json:"content"`
 	Username          string `gorm:"column:username" json:"-"`
 	TokenName         string `gorm:"column:token_name" json:"-"`
 	ModelName         string `gorm:"column:model_name" json:"model_name"`
-	Quota             int64  `gorm:"column:quota" json:"quota"` //  1美元为 500000 token，充值都是以token计算
+	Quota             int64  `gorm:"column:quota" json:"quota"` // 消耗额度，按 money.quota_per_usd 换算为美元
 	PromptTokens      int    `gorm:"column:prompt_tokens" json:"prompt_tokens"`
 	CompletionTokens  int    `gorm:"column:completion_tokens" json:"completion_tokens"`
 	UseTime           int    `gorm:"column:use_time" json:"use_time"`
@@ -40,17 +50,234 @@ type Log struct {
 	Group             string `gorm:"column:group" json:"group"`
 	Other             string `gorm:"column:other;type:text" json:"other"`
 	UpstreamModelName string `gorm:"column:upstream_model_name" json:"upstream_model_name"` // 真实模型名
+	APIKeyID          uint   `gorm:"column:api_key_id;index" json:"api_key_id"`             // 子密钥ID，0为主密钥
+}
+
+// 子密钥表，同一用户下的子密钥共享用户余额
+type APIKey struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"column:user_id;index" json:"user_id"`
+	Name         string    `gorm:"column:name;size:64" json:"name"`
+	Key          string    `gorm:"-" json:"key,omitempty"`                          // 完整key仅在创建和轮换时返回，列表中为脱敏后的key
+	KeyHash      string    `gorm:"column:key_hash;size:64;uniqueIndex" json:"-"`    // 子密钥的SHA-256
+	KeyPrefix    string    `gorm:"column:key_prefix;size:16" json:"key_prefix"`     // key的前几位，用于展示
+	Scopes       string    `gorm:"column:scopes;size:255" json:"scopes"`            // 权限范围，逗号分隔
+	AllowedIPs   string    `gorm:"column:allowed_ips;type:text" json:"allowed_ips"` // IP/CIDR白名单，逗号分隔，为空不限制
+	QuotaLimit   int64     `gorm:"column:quota_limit" json:"quota_limit"`           // 消费上限，0为不限制
+	UsedQuota    int64     `gorm:"column:used_quota" json:"used_quota"`             // 已用额度
+	DailyLimit   int64     `gorm:"column:daily_limit" json:"daily_limit"`           // 每日消费上限（额度），0为不限制
+	MonthlyLimit int64     `gorm:"column:monthly_limit" json:"monthly_limit"`       // 每月消费上限（额度），0为不限制
+	CaptureUntil int64     `gorm:"column:capture_until" json:"capture_until"`       // 内容采集截止时间戳，0为未开启
+	ExpiredTime  int64     `gorm:"column:expired_time" json:"expired_time"`         // 过期时间戳，0为永不过期
+	Status       int       `gorm:"column:status" json:"status"`                     // 状态：1正常，0已吊销
+	CreatedAt    time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// apiKeyPrefixLen 展示用前缀的长度
+const apiKeyPrefixLen = 8
+
+// SetKey 设置子密钥，数据库中只保存哈希和展示用前缀
+func (k *APIKey) SetKey(key string) {
+	k.Key = key
+	k.KeyHash = util.HashToken(key)
+	k.KeyPrefix = key[:min(len(key), apiKeyPrefixLen)]
+}
+
+// 管理员表
+type Admin struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Name       string    `gorm:"column:name;size:64;uniqueIndex" json:"name"`
+	TokenHash  string    `gorm:"column:token_hash;size:64;uniqueIndex" json:"-"` // 管理员token的SHA-256
+	Role       string    `gorm:"column:role;size:32" json:"role"`                // super_admin, finance, operator, uploader
+	Status     int       `gorm:"column:status" json:"status"`                    // 状态：1正常，0已吊销
+	CreatedBy  string    `gorm:"column:created_by;size:64" json:"created_by"`
+	LastUsedAt time.Time `gorm:"column:last_used_at" json:"last_used_at"`
+	CreatedAt  time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (Admin) TableName() string {
+	return "admins"
+}
+
+// 管理员操作审计日志表
+type AdminAuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	AdminID   uint      `gorm:"column:admin_id;index" json:"admin_id"`
+	AdminName string    `gorm:"column:admin_name;size:64;index" json:"admin_name"` // 操作人，认证失败时为空
+	Role      string    `gorm:"column:role;size:32" json:"role"`
+	Action    string    `gorm:"column:action;size:128;index" json:"action"` // 请求方法 + 路由，如 POST /api/admin/quota/add
+	Params    string    `gorm:"column:params;type:text" json:"params"`      // 脱敏后的请求参数
+	Status    int       `gorm:"column:status" json:"status"`                // HTTP状态码
+	Result    string    `gorm:"column:result;size:16;index" json:"result"`  // success, fail
+	Message   string    `gorm:"column:message;size:512" json:"message"`     // 响应信息
+	IP        string    `gorm:"column:ip;size:64" json:"ip"`
+	TraceID   string    `gorm:"column:trace_id;size:64" json:"trace_id"`
+	LatencyMs int64     `gorm:"column:latency_ms" json:"latency_ms"`
+	CreatedAt time.Time `gorm:"column:created_at;index" json:"created_at"`
+}
+
+func (AdminAuditLog) TableName() string {
+	return "admin_audit_logs"
+}
+
+// 兑换码批次表
+type RedemptionBatch struct {
+	ID           uint             `gorm:"primaryKey" json:"id"`
+	BatchNum     string           `gorm:"column:batch_num;size:64;uniqueIndex" json:"batch_num"`
+	Name         string           `gorm:"column:name;size:128" json:"name"`
+	CreatedBy    string           `gorm:"column:created_by;size:64" json:"created_by"`   // 创建的管理员
+	Type         string           `gorm:"column:type;size:32;default:quota" json:"type"` // 兑换码类型
+	Quota        int64            `gorm:"column:quota" json:"quota"`                     // 每个兑换码的额度
+	Effect       RedemptionEffect `gorm:"column:effect;type:text" json:"effect"`         // 兑换效果
+	Count        int              `gorm:"column:count" json:"count"`                     // 兑换码数量
+	MaxUses      int              `gorm:"column:max_uses;default:1" json:"max_uses"`     // 每个兑换码可兑换总次数，0为不限制
+	PerUserLimit int              `gorm:"column:per_user_limit" json:"per_user_limit"`   // 每个用户可兑换次数，0为不限制
+	NewUserOnly  bool             `gorm:"column:new_user_only" json:"new_user_only"`     // 仅限活动开始后创建的账户
+	StartTime    int64            `gorm:"column:start_time" json:"start_time"`           // 生效时间戳，0为立即生效
+	EndTime      int64            `gorm:"column:end_time" json:"end_time"`               // 过期时间戳，0为永不过期
+	Status       int              `gorm:"column:status;default:1" json:"status"`         // 状态：1正常，0已吊销
+	CreatedAt    time.Time        `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt    time.Time        `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (RedemptionBatch) TableName() string {
+	return "redemption_batches"
 }
 
 // 兑换码表
 type RedemptionCode struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Code      string    `gorm:"column:code;uniqueIndex" json:"code"`
-	Quota     int64     `gorm:"column:quota" json:"quota"` // 额度（单位：0.001美元）
-	Used      bool      `gorm:"column:used" json:"used"`   // 是否已使用
-	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
-	UsedAt    time.Time `gorm:"column:used_at" json:"used_at"`
-	UsedBy    uint      `gorm:"column:used_by" json:"used_by"` // 使用者ID
+	ID           uint             `gorm:"primaryKey" json:"id"`
+	Code         string           `gorm:"column:code;uniqueIndex" json:"code"`
+	BatchID      uint             `gorm:"column:batch_id;index" json:"batch_id"`         // 所属批次
+	Type         string           `gorm:"column:type;size:32;default:quota" json:"type"` // 兑换码类型：quota, subscription, tier, model
+	Quota        int64            `gorm:"column:quota" json:"quota"`                     // 额度，按 money.quota_per_usd 换算为美元
+	Effect       RedemptionEffect `gorm:"column:effect;type:text" json:"effect"`         // 兑换效果，JSON 存储
+	Used         bool             `gorm:"column:used" json:"used"`                       // 是否已用完
+	MaxUses      int              `gorm:"column:max_uses;default:1" json:"max_uses"`     // 可兑换总次数，0为不限制
+	UsedCount    int              `gorm:"column:used_count" json:"used_count"`           // 已兑换次数
+	PerUserLimit int              `gorm:"column:per_user_limit" json:"per_user_limit"`   // 每个用户可兑换次数，0为不限制
+	NewUserOnly  bool             `gorm:"column:new_user_only" json:"new_user_only"`     // 仅限活动开始后创建的账户
+	Status       int              `gorm:"column:status;default:1" json:"status"`         // 状态：1正常，0已吊销
+	StartTime    int64            `gorm:"column:start_time" json:"start_time"`           // 生效时间戳，0为立即生效
+	ExpiredTime  int64            `gorm:"column:expired_time" json:"expired_time"`       // 过期时间戳，0为永不过期
+	CreatedAt    time.Time        `gorm:"column:created_at" json:"created_at"`
+	UsedAt       time.Time        `gorm:"column:used_at" json:"used_at"`
+	UsedBy       uint             `gorm:"column:used_by" json:"used_by"` // 最近一次使用者ID
+}
+
+// RedemptionEffect 兑换码效果，以 JSON 存储在兑换码和批次中
+type RedemptionEffect struct {
+	Days   int      `json:"days,omitempty"`   // 订阅延长天数或等级、模型的有效天数
+	Tier   string   `json:"tier,omitempty"`   // 升级到的账户等级
+	Models []string `json:"models,omitempty"` // 解锁的显示模型
+}
+
+func (e RedemptionEffect) Value() (driver.Value, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (e *RedemptionEffect) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*e = RedemptionEffect{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for RedemptionEffect: %T", value)
+	}
+	if len(b) == 0 {
+		*e = RedemptionEffect{}
+		return nil
+	}
+	return json.Unmarshal(b, e)
+}
+
+// 用户解锁的模型
+type UserModelGrant struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"column:user_id;uniqueIndex:idx_user_model" json:"user_id"`
+	ModelName   string    `gorm:"column:model_name;size:128;uniqueIndex:idx_user_model" json:"model_name"` // 显示模型名
+	ExpiredTime int64     `gorm:"column:expired_time" json:"expired_time"`                                 // 过期时间戳，0为永久
+	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (UserModelGrant) TableName() string {
+	return "user_model_grants"
+}
+
+// 充值订单表
+type Order struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	OrderNo     string    `gorm:"column:order_no;size:64;uniqueIndex" json:"order_no"`
+	UserID      uint      `gorm:"column:user_id;index" json:"user_id"`
+	Provider    string    `gorm:"column:provider;size:32" json:"provider"`  // 支付渠道
+	Amount      int64     `gorm:"column:amount" json:"amount"`              // 金额（单位：分）
+	Quota       int64     `gorm:"column:quota" json:"quota"`                // 支付成功后到账的额度
+	Status      int       `gorm:"column:status" json:"status"`              // 状态：0待支付，1已支付，2已关闭
+	TradeNo     string    `gorm:"column:trade_no;size:128" json:"trade_no"` // 渠道交易号
+	PaidTime    int64     `gorm:"column:paid_time" json:"paid_time"`        // 支付时间戳
+	ExpiredTime int64     `gorm:"column:expired_time" json:"expired_time"`  // 支付截止时间戳
+	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (Order) TableName() string {
+	return "orders"
+}
+
+// 用户通知设置表
+type NotificationSetting struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	UserID            uint      `gorm:"column:user_id;uniqueIndex" json:"user_id"`
+	WebhookURL        string    `gorm:"column:webhook_url;size:512" json:"webhook_url"`
+	Secret            string    `gorm:"column:secret;size:64" json:"secret"`                          // HMAC 签名密钥
+	BalanceThresholds string    `gorm:"column:balance_thresholds;size:255" json:"balance_thresholds"` // 余额阈值（额度），逗号分隔
+	ExpiryDays        string    `gorm:"column:expiry_days;size:255" json:"expiry_days"`               // 到期前提醒天数，逗号分隔
+	Email             string    `gorm:"column:email;size:255" json:"email"`                           // 邮件通知地址，为空不发送邮件
+	Language          string    `gorm:"column:language;size:8" json:"language"`                       // 邮件语言：zh, en
+	Enabled           bool      `gorm:"column:enabled" json:"enabled"`
+	CreatedAt         time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt         time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (NotificationSetting) TableName() string {
+	return "notification_settings"
+}
+
+// webhook 投递记录表
+type WebhookDelivery struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"column:user_id;index" json:"user_id"`
+	EventID      string    `gorm:"column:event_id;size:64;uniqueIndex" json:"event_id"`
+	Event        string    `gorm:"column:event;size:64" json:"event"` // 事件类型
+	URL          string    `gorm:"column:url;size:512" json:"url"`
+	Payload      string    `gorm:"column:payload;type:text" json:"payload"`
+	Status       int       `gorm:"column:status;index" json:"status"` // 状态：0待投递，1成功，2失败
+	Attempts     int       `gorm:"column:attempts" json:"attempts"`   // 已投递次数
+	ResponseCode int       `gorm:"column:response_code" json:"response_code"`
+	Error        string    `gorm:"column:error;size:512" json:"error"`
+	NextRetryAt  int64     `gorm:"column:next_retry_at" json:"next_retry_at"` // 下次重试时间戳
+	CreatedAt    time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
 }
 
 // 兑换记录表
@@ -69,3 +296,99 @@ type SyncState struct {
 	LastSyncID uint      `gorm:"column:last_sync_id" json:"last_sync_id"` // 最后同步的日志ID
 	UpdatedAt  time.Time `gorm:"column:updated_at" json:"updated_at"`
 }
+
+// 按天汇总的调用统计，由日志汇总任务生成，统计接口基于此表查询
+type UsageDaily struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	Day              string    `gorm:"column:day;size:10;uniqueIndex:idx_usage_daily_key,priority:1;index" json:"day"` // 日期 2006-01-02，按服务器时区
+	UserID           uint      `gorm:"column:user_id;uniqueIndex:idx_usage_daily_key,priority:2" json:"user_id"`
+	APIKeyID         uint      `gorm:"column:api_key_id;uniqueIndex:idx_usage_daily_key,priority:3" json:"api_key_id"` // 子密钥ID，0为主密钥
+	ModelName        string    `gorm:"column:model_name;size:128;uniqueIndex:idx_usage_daily_key,priority:4" json:"model_name"`
+	Requests         int64     `gorm:"column:requests" json:"requests"`
+	Errors           int64     `gorm:"column:errors" json:"errors"`
+	PromptTokens     int64     `gorm:"column:prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int64     `gorm:"column:completion_tokens" json:"completion_tokens"`
+	Quota            int64     `gorm:"column:quota" json:"quota"`
+	TotalUseTime     int64     `gorm:"column:total_use_time" json:"total_use_time"` // 耗时合计（秒）
+	AvgUseTime       float64   `gorm:"column:avg_use_time" json:"avg_use_time"`     // 平均耗时（秒）
+	UpdatedAt        time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (UsageDaily) TableName() string {
+	return "usage_daily"
+}
+
+// 日志归档文件记录，每个归档文件一行，同一天可能有多个文件（晚到的日志单独归档）
+type LogArchive struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Day       string    `gorm:"column:day;size:10;index" json:"day"` // 日期 2006-01-02，按服务器时区
+	ObjectKey string    `gorm:"column:object_key;size:255;uniqueIndex" json:"object_key"`
+	RowCount  int64     `gorm:"column:row_count" json:"row_count"`
+	MinID     uint      `gorm:"column:min_id" json:"min_id"`
+	MaxID     uint      `gorm:"column:max_id" json:"max_id"`
+	Size      int64     `gorm:"column:size" json:"size"`             // 压缩后字节数
+	SHA256    string    `gorm:"column:sha256;size:64" json:"sha256"` // 压缩文件校验和
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (LogArchive) TableName() string {
+	return "log_archives"
+}
+
+// 请求内容采集记录，仅在密钥开启采集时写入，内容已脱敏，过期后清理
+type ContentCapture struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	UserID           uint      `gorm:"column:user_id;index:idx_content_captures_user,priority:1" json:"user_id"`
+	APIKeyID         uint      `gorm:"column:api_key_id" json:"api_key_id"` // 子密钥ID，0为主密钥
+	TraceID          string    `gorm:"column:trace_id;size:64" json:"trace_id"`
+	ModelName        string    `gorm:"column:model_name;size:128" json:"model_name"`
+	IsStream         bool      `gorm:"column:is_stream" json:"is_stream"`
+	StatusCode       int       `gorm:"column:status_code" json:"status_code"` // 上游响应状态码
+	Request          string    `gorm:"column:request;type:mediumtext" json:"request"`
+	Completion       string    `gorm:"column:completion;type:mediumtext" json:"completion"` // 流式响应按分片拼接后的完整内容
+	FinishReason     string    `gorm:"column:finish_reason;size:32" json:"finish_reason"`
+	PromptTokens     int       `gorm:"column:prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int       `gorm:"column:completion_tokens" json:"completion_tokens"`
+	Truncated        bool      `gorm:"column:truncated" json:"truncated"` // 内容超过 capture.max_bytes 被截断
+	Duration         int64     `gorm:"column:duration" json:"duration"`   // 耗时（毫秒）
+	ExpiresAt        int64     `gorm:"column:expires_at;index" json:"expires_at"`
+	CreatedAt        time.Time `gorm:"column:created_at;index:idx_content_captures_user,priority:2" json:"created_at"`
+}
+
+func (ContentCapture) TableName() string {
+	return "content_captures"
+}
+
+// 网关自身记录的调用，请求结束时写入，上游日志同步后关联，上游没有日志的失败请求同样可查
+type RequestRecord struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	TraceID          string    `gorm:"column:trace_id;size:64;index" json:"trace_id"`
+	UserID           uint      `gorm:"column:user_id;index:idx_request_records_user_created,priority:1" json:"user_id"`
+	APIKeyID         uint      `gorm:"column:api_key_id" json:"api_key_id"`                  // 子密钥ID，0为主密钥
+	Model            string    `gorm:"column:model;size:128" json:"model"`                   // 客户端请求的显示模型
+	UpstreamModel    string    `gorm:"column:upstream_model;size:128" json:"upstream_model"` // 映射后实际调用的模型
+	Attempts         int       `gorm:"column:attempts" json:"attempts"`                      // 上游请求次数，0为未转发
+	Status           string    `gorm:"column:status;size:32;index" json:"status"`            // success, upstream_error, failed, rejected, canceled
+	StatusCode       int       `gorm:"column:status_code" json:"status_code"`                // 返回给客户端的状态码
+	UpstreamStatus   int       `gorm:"column:upstream_status" json:"upstream_status"`        // 上游响应状态码
+	Error            string    `gorm:"column:error;size:512" json:"error"`
+	IsStream         bool      `gorm:"column:is_stream" json:"is_stream"`
+	TTFT             int64     `gorm:"column:ttft" json:"ttft"`       // 首字节耗时（毫秒），非流式为上游响应耗时
+	Latency          int64     `gorm:"column:latency" json:"latency"` // 总耗时（毫秒）
+	PromptTokens     int       `gorm:"column:prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int       `gorm:"column:completion_tokens" json:"completion_tokens"`
+	TotalTokens      int       `gorm:"column:total_tokens" json:"total_tokens"`
+	ClientIP         string    `gorm:"column:client_ip;size:64" json:"client_ip"`
+	UserAgent        string    `gorm:"column:user_agent;size:255" json:"user_agent"`
+	Browser          string    `gorm:"column:browser;size:64" json:"browser"`
+	OS               string    `gorm:"column:os;size:64" json:"os"`
+	DeviceType       string    `gorm:"column:device_type;size:32" json:"device_type"`
+	Referer          string    `gorm:"column:referer;size:255" json:"referer"`
+	LogID            uint      `gorm:"column:log_id;index" json:"log_id"`         // 关联的同步日志ID，0为未关联
+	RemoteLogID      uint      `gorm:"column:remote_log_id" json:"remote_log_id"` // New API 中的日志ID
+	CreatedAt        time.Time `gorm:"column:created_at;index:idx_request_records_user_created,priority:2;index:idx_request_records_created" json:"created_at"`
+}
+
+func (RequestRecord) TableName() string {
+	return "request_records"
+}
@@ -0,0 +1,126 @@
+// internal/model/migrate.go
+package model
+
+import "gorm.io/gorm"
+
+type fieldMigration struct {
+	model interface{}
+	field string
+}
+
+// 网关新增的表
+var addedTables = []interface{}{
+	&APIKey{},
+	&Admin{},
+	&AdminAuditLog{},
+	&RedemptionBatch{},
+	&UserModelGrant{},
+	&Order{},
+	&NotificationSetting{},
+	&WebhookDelivery{},
+	&UsageDaily{},
+	&LogArchive{},
+	&ContentCapture{},
+	&RequestRecord{},
+}
+
+// 网关新增的字段，已有表只补字段不改动原有列
+var addedColumns = []fieldMigration{
+	{&User{}, "Tier"},
+	{&User{}, "TierExpiredTime"},
+	{&User{}, "DailyLimit"},
+	{&User{}, "MonthlyLimit"},
+	{&User{}, "CaptureUntil"},
+	{&Log{}, "APIKeyID"},
+	{&RedemptionCode{}, "BatchID"},
+	{&RedemptionCode{}, "Status"},
+	{&RedemptionCode{}, "StartTime"},
+	{&RedemptionCode{}, "ExpiredTime"},
+	{&RedemptionCode{}, "MaxUses"},
+	{&RedemptionCode{}, "UsedCount"},
+	{&RedemptionCode{}, "PerUserLimit"},
+	{&RedemptionCode{}, "NewUserOnly"},
+	{&RedemptionCode{}, "Type"},
+	{&RedemptionCode{}, "Effect"},
+}
+
+// 网关新增的索引，field 可以是字段名或复合索引名
+var addedIndexes = []fieldMigration{
+	{&Log{}, "APIKeyID"},
+	{&Log{}, "idx_logs_user_created"},      // 日志游标分页 (user_id, created_at)，InnoDB 二级索引隐含主键 id
+	{&Log{}, "idx_logs_user_type_created"}, // 按类型过滤日志
+	{&Log{}, "idx_logs_created"},           // 按天汇总日志
+	{&RedemptionCode{}, "BatchID"},
+}
+
+// AutoMigrate 迁移调用层数据库中网关新增的表、字段和索引
+func AutoMigrate(db *gorm.DB) error {
+	if err := migrateAPIKeyHashes(db); err != nil {
+		return err
+	}
+
+	if err := db.AutoMigrate(addedTables...); err != nil {
+		return err
+	}
+
+	migrator := db.Migrator()
+	for _, c := range addedColumns {
+		if migrator.HasColumn(c.model, c.field) {
+			continue
+		}
+		if err := migrator.AddColumn(c.model, c.field); err != nil {
+			return err
+		}
+	}
+
+	for _, idx := range addedIndexes {
+		if migrator.HasIndex(idx.model, idx.field) {
+			continue
+		}
+		if err := migrator.CreateIndex(idx.model, idx.field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateAPIKeyHashes 子密钥由明文改为保存哈希，回填哈希和前缀后删除明文列
+func migrateAPIKeyHashes(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&APIKey{}) || !migrator.HasColumn(&APIKey{}, "key") {
+		return nil
+	}
+
+	for _, field := range []string{"KeyHash", "KeyPrefix"} {
+		if migrator.HasColumn(&APIKey{}, field) {
+			continue
+		}
+		if err := migrator.AddColumn(&APIKey{}, field); err != nil {
+			return err
+		}
+	}
+
+	var rows []struct {
+		ID  uint
+		Key string
+	}
+	if err := db.Table("api_keys").
+		Select("id", "`key`").
+		Where("key_hash IS NULL OR key_hash = ''").
+		Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		var apiKey APIKey
+		apiKey.SetKey(row.Key)
+		if err := db.Table("api_keys").Where("id = ?", row.ID).Updates(map[string]interface{}{
+			"key_hash":   apiKey.KeyHash,
+			"key_prefix": apiKey.KeyPrefix,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return migrator.DropColumn(&APIKey{}, "key")
+}
@@ -0,0 +1,176 @@
+// internal/service/admin_service.go
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"llmapisrv/config"
+	"llmapisrv/internal/model"
+	"llmapisrv/pkg/util"
+)
+
+// 管理员角色
+const (
+	AdminRoleSuperAdmin = "super_admin" // 全部权限，含管理员账号管理
+	AdminRoleFinance    = "finance"     // 兑换码、额度
+	AdminRoleOperator   = "operator"    // 同步、日志清理
+	AdminRoleUploader   = "uploader"    // 图片上传
+)
+
+// BootstrapAdminName 初始超级管理员的名称，只能用于创建第一个超级管理员
+const BootstrapAdminName = "bootstrap"
+
+// ErrBootstrapRole 初始超级管理员只能创建超级管理员
+var ErrBootstrapRole = errors.New("bootstrap token can only create a super admin")
+
+// AllAdminRoles 所有管理员角色
+var AllAdminRoles = []string{AdminRoleSuperAdmin, AdminRoleFinance, AdminRoleOperator, AdminRoleUploader}
+
+type AdminService struct {
+	gatewayDB *gorm.DB
+	config    *config.Config
+}
+
+func NewAdminService(gatewayDB *gorm.DB, config *config.Config) *AdminService {
+	return &AdminService{
+		gatewayDB: gatewayDB,
+		config:    config,
+	}
+}
+
+// Authenticate 校验管理员token
+func (s *AdminService) Authenticate(token string) (*model.Admin, error) {
+	if token == "" {
+		return nil, fmt.Errorf("admin token is required")
+	}
+
+	// 初始超级管理员，仅用于创建第一个管理员账号，已有可用的超级管理员后失效
+	bootstrap := s.config.Admin.BootstrapToken
+	if bootstrap != "" && subtle.ConstantTimeCompare([]byte(token), []byte(bootstrap)) == 1 {
+		var count int64
+		if err := s.gatewayDB.Model(&model.Admin{}).
+			Where("role = ? AND status = ?", AdminRoleSuperAdmin, 1).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("bootstrap token is disabled once a super admin exists")
+		}
+		return &model.Admin{Name: BootstrapAdminName, Role: AdminRoleSuperAdmin, Status: 1}, nil
+	}
+
+	var admin model.Admin
+	if err := s.gatewayDB.Where("token_hash = ?", util.HashToken(token)).First(&admin).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("invalid admin token")
+		}
+		return nil, err
+	}
+	if admin.Status != 1 {
+		return nil, fmt.Errorf("admin token has been revoked")
+	}
+
+	s.gatewayDB.Model(&admin).UpdateColumn("last_used_at", time.Now())
+
+	return &admin, nil
+}
+
+// CreateAdmin 创建管理员，返回仅展示一次的token
+// 初始超级管理员只能创建超级管理员，其余角色需由创建出的超级管理员分配
+func (s *AdminService) CreateAdmin(name, role string, creator *model.Admin) (*model.Admin, string, error) {
+	if !isValidAdminRole(role) {
+		return nil, "", fmt.Errorf("invalid role: %s", role)
+	}
+	if IsBootstrapAdmin(creator) && role != AdminRoleSuperAdmin {
+		return nil, "", ErrBootstrapRole
+	}
+
+	token, err := generateAdminToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	admin := model.Admin{
+		Name:      name,
+		TokenHash: util.HashToken(token),
+		Role:      role,
+		Status:    1,
+		CreatedBy: creator.Name,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := s.gatewayDB.Create(&admin).Error; err != nil {
+		return nil, "", err
+	}
+
+	return &admin, token, nil
+}
+
+// ListAdmins 获取管理员列表
+func (s *AdminService) ListAdmins() ([]model.Admin, error) {
+	var admins []model.Admin
+	if err := s.gatewayDB.Order("id ASC").Find(&admins).Error; err != nil {
+		return nil, err
+	}
+	return admins, nil
+}
+
+// RevokeAdmin 吊销管理员token
+func (s *AdminService) RevokeAdmin(id uint) error {
+	result := s.gatewayDB.Model(&model.Admin{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     0,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("管理员不存在")
+	}
+	return nil
+}
+
+// IsBootstrapAdmin 判断是否为配置文件中的初始超级管理员
+func IsBootstrapAdmin(admin *model.Admin) bool {
+	return admin.ID == 0 && admin.Name == BootstrapAdminName
+}
+
+// HasRole 判断管理员是否拥有任一角色，超级管理员拥有全部权限
+func HasRole(admin *model.Admin, roles ...string) bool {
+	if admin.Role == AdminRoleSuperAdmin {
+		return true
+	}
+	for _, role := range roles {
+		if admin.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidAdminRole(role string) bool {
+	for _, v := range AllAdminRoles {
+		if role == v {
+			return true
+		}
+	}
+	return false
+}
+
+func generateAdminToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "adm-" + hex.EncodeToString(b), nil
+}
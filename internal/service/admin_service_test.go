@@ -0,0 +1,60 @@
+// internal/service/admin_service_test.go
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"llmapisrv/config"
+	"llmapisrv/internal/model"
+)
+
+func TestBootstrapAdminCreatesOnlySuperAdmin(t *testing.T) {
+	db := openTestDB(t, "gateway")
+	if err := db.AutoMigrate(&model.Admin{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	cfg := &config.Config{}
+	cfg.Admin.BootstrapToken = "bootstrap-secret"
+	svc := NewAdminService(db, cfg)
+
+	bootstrap, err := svc.Authenticate("bootstrap-secret")
+	if err != nil {
+		t.Fatalf("authenticate bootstrap: %v", err)
+	}
+	if !IsBootstrapAdmin(bootstrap) {
+		t.Fatalf("bootstrap token resolved to %+v, want bootstrap admin", bootstrap)
+	}
+
+	// 初始超级管理员不能创建其他角色
+	for _, role := range []string{AdminRoleFinance, AdminRoleOperator, AdminRoleUploader} {
+		if _, _, err := svc.CreateAdmin("ops", role, bootstrap); !errors.Is(err, ErrBootstrapRole) {
+			t.Errorf("bootstrap create %s error = %v, want ErrBootstrapRole", role, err)
+		}
+	}
+	var count int64
+	db.Model(&model.Admin{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("admins = %d after rejected creates, want 0", count)
+	}
+
+	superAdmin, token, err := svc.CreateAdmin("root", AdminRoleSuperAdmin, bootstrap)
+	if err != nil {
+		t.Fatalf("bootstrap create super admin: %v", err)
+	}
+	if superAdmin.CreatedBy != BootstrapAdminName {
+		t.Errorf("created_by = %q, want %q", superAdmin.CreatedBy, BootstrapAdminName)
+	}
+
+	// 已有超级管理员后初始 token 失效，由超级管理员分配其他角色
+	if _, err := svc.Authenticate("bootstrap-secret"); err == nil {
+		t.Error("bootstrap token still valid after a super admin exists")
+	}
+	authenticated, err := svc.Authenticate(token)
+	if err != nil {
+		t.Fatalf("authenticate super admin: %v", err)
+	}
+	if _, _, err := svc.CreateAdmin("ops", AdminRoleFinance, authenticated); err != nil {
+		t.Errorf("super admin create finance: %v", err)
+	}
+}
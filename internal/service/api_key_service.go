@@ -0,0 +1,262 @@
+// internal/service/api_key_service.go
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"llmapisrv/internal/model"
+	"llmapisrv/pkg/util"
+)
+
+// 子密钥权限范围
+const (
+	ScopeChat        = "chat"
+	ScopeBillingRead = "billing:read"
+	ScopeLogsRead    = "logs:read"
+)
+
+// AllScopes 所有可分配给子密钥的权限
+var AllScopes = []string{ScopeChat, ScopeBillingRead, ScopeLogsRead}
+
+// CreateAPIKeyParams 创建子密钥参数
+type CreateAPIKeyParams struct {
+	Name        string
+	Scopes      []string
+	AllowedIPs  []string
+	QuotaLimit  int64
+	ExpiredTime int64
+}
+
+type APIKeyService struct {
+	gatewayDB   *gorm.DB
+	userService *UserService
+	authCache   *AuthCacheService
+}
+
+func NewAPIKeyService(gatewayDB *gorm.DB, userService *UserService, authCache *AuthCacheService) *APIKeyService {
+	return &APIKeyService{
+		gatewayDB:   gatewayDB,
+		userService: userService,
+		authCache:   authCache,
+	}
+}
+
+// ResolveAuthSnapshot 根据请求携带的key构建鉴权快照，子密钥解析到所属用户
+func (s *APIKeyService) ResolveAuthSnapshot(ctx context.Context, key string) (*AuthSnapshot, error) {
+	var apiKey model.APIKey
+	err := s.gatewayDB.WithContext(ctx).Where("key_hash = ?", util.HashToken(key)).First(&apiKey).Error
+	if err == gorm.ErrRecordNotFound {
+		// 不是子密钥，按主密钥处理
+		user, err := s.userService.GetUserByAPIKey(key)
+		if err != nil {
+			return nil, err
+		}
+		snapshot := NewAuthSnapshot(user)
+		if snapshot.ModelGrants, err = s.userService.GetModelGrants(user.ID); err != nil {
+			return nil, err
+		}
+		return snapshot, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	parent, err := s.userService.GetUserByID(apiKey.UserID)
+	if err != nil {
+		return nil, err
+	}
+	// 刷新所属用户的状态和过期时间
+	if parent, err = s.userService.GetUserByAPIKey(parent.APIKey); err != nil {
+		return nil, err
+	}
+
+	snapshot := NewAuthSnapshot(parent)
+	if snapshot.ModelGrants, err = s.userService.GetModelGrants(parent.ID); err != nil {
+		return nil, err
+	}
+	snapshot.APIKeyID = apiKey.ID
+	snapshot.Scopes = splitList(apiKey.Scopes)
+	snapshot.AllowedIPs = splitList(apiKey.AllowedIPs)
+	snapshot.CaptureUntil = apiKey.CaptureUntil
+	if apiKey.Status != 1 {
+		snapshot.Status = apiKey.Status
+	}
+	if apiKey.ExpiredTime > 0 && (snapshot.ExpiredTime == 0 || apiKey.ExpiredTime < snapshot.ExpiredTime) {
+		snapshot.ExpiredTime = apiKey.ExpiredTime
+	}
+
+	return snapshot, nil
+}
+
+// CreateKey 创建子密钥
+func (s *APIKeyService) CreateKey(userID uint, params CreateAPIKeyParams) (*model.APIKey, error) {
+	if err := validateScopes(params.Scopes); err != nil {
+		return nil, err
+	}
+	if err := util.ValidateIPList(params.AllowedIPs); err != nil {
+		return nil, err
+	}
+
+	key, err := generateSubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey := model.APIKey{
+		UserID:      userID,
+		Name:        params.Name,
+		Scopes:      strings.Join(params.Scopes, ","),
+		AllowedIPs:  strings.Join(params.AllowedIPs, ","),
+		QuotaLimit:  params.QuotaLimit,
+		ExpiredTime: params.ExpiredTime,
+		Status:      1,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	apiKey.SetKey(key)
+
+	if err := s.gatewayDB.Create(&apiKey).Error; err != nil {
+		return nil, err
+	}
+
+	return &apiKey, nil
+}
+
+// ListKeys 获取用户的所有子密钥
+func (s *APIKeyService) ListKeys(userID uint) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	if err := s.gatewayDB.Where("user_id = ?", userID).
+		Order("id DESC").
+		Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeKey 吊销子密钥
+func (s *APIKeyService) RevokeKey(userID, id uint) error {
+	apiKey, err := s.getUserKey(userID, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.gatewayDB.Model(apiKey).Updates(map[string]interface{}{
+		"status":     0,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+
+	s.authCache.PurgeHashes(apiKey.KeyHash)
+	return nil
+}
+
+// RotateKey 轮换子密钥，旧key立即失效
+func (s *APIKeyService) RotateKey(userID, id uint) (*model.APIKey, error) {
+	apiKey, err := s.getUserKey(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.Status != 1 {
+		return nil, fmt.Errorf("子密钥已吊销")
+	}
+
+	oldKeyHash := apiKey.KeyHash
+	newKey, err := generateSubKey()
+	if err != nil {
+		return nil, err
+	}
+	apiKey.SetKey(newKey)
+
+	if err := s.gatewayDB.Model(apiKey).Updates(map[string]interface{}{
+		"key_hash":   apiKey.KeyHash,
+		"key_prefix": apiKey.KeyPrefix,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	s.authCache.PurgeHashes(oldKeyHash)
+	return apiKey, nil
+}
+
+// CheckQuotaLimit 检查子密钥是否超出消费上限
+func (s *APIKeyService) CheckQuotaLimit(ctx context.Context, id uint) error {
+	if id == 0 {
+		return nil
+	}
+
+	var apiKey model.APIKey
+	if err := s.gatewayDB.WithContext(ctx).Select("quota_limit", "used_quota").First(&apiKey, id).Error; err != nil {
+		return err
+	}
+
+	if apiKey.QuotaLimit > 0 && apiKey.UsedQuota >= apiKey.QuotaLimit {
+		return fmt.Errorf("API key spending limit exceeded")
+	}
+	return nil
+}
+
+// RecordUsage 累加子密钥已用额度
+func (s *APIKeyService) RecordUsage(id uint, quota int64) error {
+	return s.gatewayDB.Model(&model.APIKey{}).
+		Where("id = ?", id).
+		Update("used_quota", gorm.Expr("used_quota + ?", quota)).Error
+}
+
+func (s *APIKeyService) getUserKey(userID, id uint) (*model.APIKey, error) {
+	var apiKey model.APIKey
+	if err := s.gatewayDB.Where("id = ? AND user_id = ?", id, userID).First(&apiKey).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("子密钥不存在")
+		}
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+// generateSubKey 生成子密钥，长度与 New API 的 key 保持一致
+func generateSubKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("scopes is required")
+	}
+	for _, scope := range scopes {
+		valid := false
+		for _, v := range AllScopes {
+			if scope == v {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid scope: %s", scope)
+		}
+	}
+	return nil
+}
+
+// splitList 拆分逗号分隔的字符串，忽略空项
+func splitList(s string) []string {
+	var result []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
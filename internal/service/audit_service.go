@@ -0,0 +1,116 @@
+// internal/service/audit_service.go
+package service
+
+import (
+	"io"
+	"time"
+
+	"gorm.io/gorm"
+
+	"llmapisrv/internal/model"
+	"llmapisrv/pkg/export"
+)
+
+// AuditFilter 审计日志查询条件
+type AuditFilter struct {
+	AdminName string
+	Action    string
+	Result    string
+	StartTime int64 // unix 秒
+	EndTime   int64
+}
+
+type AuditService struct {
+	gatewayDB *gorm.DB
+}
+
+func NewAuditService(gatewayDB *gorm.DB) *AuditService {
+	return &AuditService{
+		gatewayDB: gatewayDB,
+	}
+}
+
+// Record 写入审计日志
+func (s *AuditService) Record(entry *model.AdminAuditLog) error {
+	return s.gatewayDB.Create(entry).Error
+}
+
+// Query 分页查询审计日志
+func (s *AuditService) Query(filter AuditFilter, page, pageSize int) ([]model.AdminAuditLog, int64, error) {
+	var logs []model.AdminAuditLog
+	var total int64
+
+	if err := s.applyFilter(filter).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := s.applyFilter(filter).
+		Order("id DESC").
+		Limit(pageSize).
+		Offset(offset).
+		Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
+
+// ExportCSV 按条件逐行导出审计日志，参数、消息等文本以公式字符开头时转义
+func (s *AuditService) ExportCSV(filter AuditFilter, w io.Writer) error {
+	rows, err := s.applyFilter(filter).Order("id ASC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	writer := export.NewCSVWriter(w)
+	if err := writer.Write([]interface{}{"id", "created_at", "admin_name", "role", "action", "params", "status", "result", "message", "ip", "trace_id", "latency_ms"}); err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var entry model.AdminAuditLog
+		if err := s.gatewayDB.ScanRows(rows, &entry); err != nil {
+			return err
+		}
+		if err := writer.Write([]interface{}{
+			entry.ID,
+			entry.CreatedAt.Format(time.RFC3339),
+			entry.AdminName,
+			entry.Role,
+			entry.Action,
+			entry.Params,
+			entry.Status,
+			entry.Result,
+			entry.Message,
+			entry.IP,
+			entry.TraceID,
+			entry.LatencyMs,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+func (s *AuditService) applyFilter(filter AuditFilter) *gorm.DB {
+	query := s.gatewayDB.Model(&model.AdminAuditLog{})
+	if filter.AdminName != "" {
+		query = query.Where("admin_name = ?", filter.AdminName)
+	}
+	if filter.Action != "" {
+		query = query.Where("action LIKE ?", "%"+filter.Action+"%")
+	}
+	if filter.Result != "" {
+		query = query.Where("result = ?", filter.Result)
+	}
+	if filter.StartTime > 0 {
+		query = query.Where("created_at >= ?", time.Unix(filter.StartTime, 0))
+	}
+	if filter.EndTime > 0 {
+		query = query.Where("created_at < ?", time.Unix(filter.EndTime, 0))
+	}
+	return query
+}
@@ -0,0 +1,240 @@
+// internal/service/auth_cache.go
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"llmapisrv/internal/model"
+	"llmapisrv/pkg/cache"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/util"
+)
+
+const (
+	authCachePrefix       = "auth:"
+	authInvalidateChannel = "auth:invalidate"
+	authCacheTTL          = 2 * 3600 // Redis 中快照保留两小时
+	authLocalTTL          = 60 * time.Second
+)
+
+// DefaultTier 默认账户等级
+const DefaultTier = "default"
+
+var (
+	ErrAPIKeyDisabled = errors.New("API key is disabled")
+	ErrAPIKeyExpired  = errors.New("API key has expired")
+)
+
+// AuthSnapshot 鉴权缓存快照，命中缓存时仍需重新校验
+type AuthSnapshot struct {
+	UserID          uint             `json:"user_id"`
+	APIKey          string           `json:"api_key"`    // 所属用户的主密钥，用于调用上游
+	APIKeyID        uint             `json:"api_key_id"` // 子密钥ID，0为主密钥
+	Scopes          []string         `json:"scopes"`
+	AllowedIPs      []string         `json:"allowed_ips"` // 子密钥IP/CIDR白名单
+	Status          int              `json:"status"`
+	ExpiredTime     int64            `json:"expired_time"`
+	Tier            string           `json:"tier"`
+	TierExpiredTime int64            `json:"tier_expired_time"`
+	ModelGrants     map[string]int64 `json:"model_grants"`  // 已解锁的模型及过期时间，0为永久
+	CaptureUntil    int64            `json:"capture_until"` // 内容采集截止时间戳，按当前密钥取值
+}
+
+// NewAuthSnapshot 根据用户信息构建鉴权快照
+func NewAuthSnapshot(user *model.User) *AuthSnapshot {
+	return &AuthSnapshot{
+		UserID:          user.ID,
+		APIKey:          user.APIKey,
+		Status:          user.Status,
+		ExpiredTime:     user.ExpiredTime,
+		Tier:            user.Tier,
+		TierExpiredTime: user.TierExpiredTime,
+		CaptureUntil:    user.CaptureUntil,
+	}
+}
+
+// CurrentTier 当前生效的账户等级，升级到期后回到默认等级
+func (s *AuthSnapshot) CurrentTier() string {
+	if s.Tier == "" || (s.TierExpiredTime > 0 && s.TierExpiredTime < time.Now().Unix()) {
+		return DefaultTier
+	}
+	return s.Tier
+}
+
+// CaptureActive 当前密钥是否开启了内容采集
+func (s *AuthSnapshot) CaptureActive() bool {
+	return s.CaptureUntil > time.Now().Unix()
+}
+
+// CanUseModel 判断是否可以使用模型，需解锁的模型要有未过期的解锁记录
+func (s *AuthSnapshot) CanUseModel(modelName string, lockedModels []string) bool {
+	locked := false
+	for _, v := range lockedModels {
+		if v == modelName {
+			locked = true
+			break
+		}
+	}
+	if !locked {
+		return true
+	}
+
+	expiredTime, ok := s.ModelGrants[modelName]
+	return ok && (expiredTime == 0 || expiredTime > time.Now().Unix())
+}
+
+// HasScope 判断是否拥有指定权限，主密钥拥有全部权限
+func (s *AuthSnapshot) HasScope(scope string) bool {
+	if s.APIKeyID == 0 {
+		return true
+	}
+	for _, v := range s.Scopes {
+		if v == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 校验快照中的状态和过期时间
+func (s *AuthSnapshot) Validate() error {
+	if s.Status != 1 {
+		return ErrAPIKeyDisabled
+	}
+	if s.ExpiredTime > 0 && s.ExpiredTime < time.Now().Unix() {
+		return ErrAPIKeyExpired
+	}
+	return nil
+}
+
+type localAuthEntry struct {
+	snapshot  *AuthSnapshot
+	expiresAt time.Time
+}
+
+// AuthCacheService 鉴权快照缓存，本地内存 + Redis 两级，通过 Redis pub/sub 在各副本间失效
+type AuthCacheService struct {
+	gatewayDB *gorm.DB
+	cache     *cache.RedisCache
+	mu        sync.RWMutex
+	local     map[string]localAuthEntry
+}
+
+func NewAuthCacheService(gatewayDB *gorm.DB, cache *cache.RedisCache) *AuthCacheService {
+	return &AuthCacheService{
+		gatewayDB: gatewayDB,
+		cache:     cache,
+		local:     make(map[string]localAuthEntry),
+	}
+}
+
+// Get 获取鉴权快照，先查本地再查Redis，缓存按key的哈希保存
+func (s *AuthCacheService) Get(ctx context.Context, apiKey string) (*AuthSnapshot, bool) {
+	keyHash := util.HashToken(apiKey)
+	s.mu.RLock()
+	entry, ok := s.local[keyHash]
+	s.mu.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.snapshot, true
+	}
+
+	data, err := s.cache.WithContext(ctx).Get(authCachePrefix + keyHash)
+	if err != nil {
+		return nil, false
+	}
+
+	var snapshot AuthSnapshot
+	if err := json.Unmarshal([]byte(data), &snapshot); err != nil || snapshot.UserID == 0 {
+		// 兼容旧格式（仅缓存了用户ID），直接视为未命中
+		return nil, false
+	}
+
+	s.setLocal(keyHash, &snapshot)
+	return &snapshot, true
+}
+
+// Set 写入鉴权快照
+func (s *AuthCacheService) Set(ctx context.Context, apiKey string, snapshot *AuthSnapshot) {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return
+	}
+	keyHash := util.HashToken(apiKey)
+	if err := s.cache.WithContext(ctx).Set(authCachePrefix+keyHash, string(data), authCacheTTL); err != nil {
+		logger.Errorf("AuthCacheService set cache failed: %v", err)
+	}
+	s.setLocal(keyHash, snapshot)
+}
+
+// Purge 删除指定key的鉴权快照，并通知其他副本清除本地缓存
+func (s *AuthCacheService) Purge(apiKeys ...string) {
+	keyHashes := make([]string, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		if apiKey != "" {
+			keyHashes = append(keyHashes, util.HashToken(apiKey))
+		}
+	}
+	s.PurgeHashes(keyHashes...)
+}
+
+// PurgeHashes 按key的哈希删除鉴权快照，子密钥只保存了哈希
+func (s *AuthCacheService) PurgeHashes(keyHashes ...string) {
+	for _, keyHash := range keyHashes {
+		if keyHash == "" {
+			continue
+		}
+		if err := s.cache.Delete(authCachePrefix + keyHash); err != nil {
+			logger.Errorf("AuthCacheService delete cache failed: %v", err)
+		}
+		s.deleteLocal(keyHash)
+		if err := s.cache.Publish(authInvalidateChannel, keyHash); err != nil {
+			logger.Errorf("AuthCacheService publish invalidation failed: %v", err)
+		}
+	}
+}
+
+// PurgeUser 删除用户主密钥及其所有子密钥的鉴权快照
+func (s *AuthCacheService) PurgeUser(user *model.User) {
+	var subKeyHashes []string
+	if err := s.gatewayDB.Model(&model.APIKey{}).
+		Where("user_id = ?", user.ID).
+		Pluck("key_hash", &subKeyHashes).Error; err != nil {
+		logger.Errorf("AuthCacheService load sub keys failed: %v", err)
+	}
+
+	s.Purge(user.APIKey)
+	s.PurgeHashes(subKeyHashes...)
+}
+
+// StartInvalidationListener 启动失效通知监听
+func (s *AuthCacheService) StartInvalidationListener() {
+	go func() {
+		pubsub := s.cache.Subscribe(authInvalidateChannel)
+		defer pubsub.Close()
+
+		for msg := range pubsub.Channel() {
+			s.deleteLocal(msg.Payload)
+		}
+	}()
+}
+
+func (s *AuthCacheService) setLocal(keyHash string, snapshot *AuthSnapshot) {
+	s.mu.Lock()
+	s.local[keyHash] = localAuthEntry{
+		snapshot:  snapshot,
+		expiresAt: time.Now().Add(authLocalTTL),
+	}
+	s.mu.Unlock()
+}
+
+func (s *AuthCacheService) deleteLocal(keyHash string) {
+	s.mu.Lock()
+	delete(s.local, keyHash)
+	s.mu.Unlock()
+}
@@ -0,0 +1,250 @@
+// internal/service/capture_service.go
+package service
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"llmapisrv/config"
+	"llmapisrv/internal/model"
+	"llmapisrv/pkg/logger"
+)
+
+// CaptureRecord 一次调用的原始内容，保存前脱敏
+type CaptureRecord struct {
+	UserID           uint
+	APIKeyID         uint
+	TraceID          string
+	ModelName        string
+	IsStream         bool
+	StatusCode       int
+	Request          []byte // 客户端请求体
+	Completion       string // 回复内容，流式响应为拼接后的完整内容
+	FinishReason     string
+	PromptTokens     int
+	CompletionTokens int
+	Duration         time.Duration
+}
+
+// CaptureService 按密钥开启的请求内容采集，内容脱敏后入库，保留 capture.ttl_hours 后清理
+type CaptureService struct {
+	db        *gorm.DB
+	authCache *AuthCacheService
+	config    *config.Config
+}
+
+func NewCaptureService(db *gorm.DB, authCache *AuthCacheService, cfg *config.Config) *CaptureService {
+	return &CaptureService{
+		db:        db,
+		authCache: authCache,
+		config:    cfg,
+	}
+}
+
+// SetUserCapture 为主密钥开启采集 hours 小时，hours 为0时关闭，返回采集截止时间戳
+func (s *CaptureService) SetUserCapture(userID uint, hours int) (int64, error) {
+	captureUntil, err := s.captureUntil(hours)
+	if err != nil {
+		return 0, err
+	}
+
+	var user model.User
+	if err := s.db.Select("id", "api_key").First(&user, userID).Error; err != nil {
+		return 0, err
+	}
+	if err := s.db.Model(&user).Updates(map[string]interface{}{
+		"capture_until": captureUntil,
+		"updated_at":    time.Now(),
+	}).Error; err != nil {
+		return 0, err
+	}
+
+	// 子密钥单独开启采集，只刷新主密钥的鉴权快照
+	s.authCache.Purge(user.APIKey)
+	return captureUntil, nil
+}
+
+// SetKeyCapture 为子密钥开启采集 hours 小时，hours 为0时关闭
+func (s *CaptureService) SetKeyCapture(userID, apiKeyID uint, hours int) (*model.APIKey, error) {
+	captureUntil, err := s.captureUntil(hours)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiKey model.APIKey
+	if err := s.db.Where("id = ? AND user_id = ?", apiKeyID, userID).First(&apiKey).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("子密钥不存在")
+		}
+		return nil, err
+	}
+
+	if err := s.db.Model(&apiKey).Updates(map[string]interface{}{
+		"capture_until": captureUntil,
+		"updated_at":    time.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	s.authCache.PurgeHashes(apiKey.KeyHash)
+	apiKey.CaptureUntil = captureUntil
+	return &apiKey, nil
+}
+
+func (s *CaptureService) captureUntil(hours int) (int64, error) {
+	if hours == 0 {
+		return 0, nil
+	}
+
+	maxHours := s.config.Capture.MaxWindowHours
+	if maxHours <= 0 {
+		maxHours = 168
+	}
+	if hours < 0 || hours > maxHours {
+		return 0, fmt.Errorf("采集时长需在1到%d小时之间", maxHours)
+	}
+	return time.Now().Add(time.Duration(hours) * time.Hour).Unix(), nil
+}
+
+// DefaultWindowHours 未指定时长时默认开启的采集时长
+func (s *CaptureService) DefaultWindowHours() int {
+	if s.config.Capture.DefaultWindowHours > 0 {
+		return s.config.Capture.DefaultWindowHours
+	}
+	return 24
+}
+
+// Save 脱敏后保存一次调用的内容
+func (s *CaptureService) Save(rec *CaptureRecord) error {
+	maxBytes := s.config.Capture.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = 256 * 1024
+	}
+	ttlHours := s.config.Capture.TTLHours
+	if ttlHours <= 0 {
+		ttlHours = 72
+	}
+
+	request, requestTruncated := truncateUTF8(logger.RedactContent(rec.Request), maxBytes)
+	completion, completionTruncated := truncateUTF8(logger.RedactContentText(rec.Completion), maxBytes)
+
+	now := time.Now()
+	capture := model.ContentCapture{
+		UserID:           rec.UserID,
+		APIKeyID:         rec.APIKeyID,
+		TraceID:          rec.TraceID,
+		ModelName:        truncate(rec.ModelName, 128),
+		IsStream:         rec.IsStream,
+		StatusCode:       rec.StatusCode,
+		Request:          request,
+		Completion:       completion,
+		FinishReason:     truncate(rec.FinishReason, 32),
+		PromptTokens:     rec.PromptTokens,
+		CompletionTokens: rec.CompletionTokens,
+		Truncated:        requestTruncated || completionTruncated,
+		Duration:         rec.Duration.Milliseconds(),
+		ExpiresAt:        now.Add(time.Duration(ttlHours) * time.Hour).Unix(),
+		CreatedAt:        now,
+	}
+	return s.db.Create(&capture).Error
+}
+
+// ListCaptures 分页获取采集记录，不含请求和回复内容，userID 为0时查询所有用户
+func (s *CaptureService) ListCaptures(userID uint, page, pageSize int) ([]model.ContentCapture, int64, error) {
+	query := func() *gorm.DB {
+		q := s.db.Model(&model.ContentCapture{}).Where("expires_at > ?", time.Now().Unix())
+		if userID > 0 {
+			q = q.Where("user_id = ?", userID)
+		}
+		return q
+	}
+
+	var total int64
+	if err := query().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var captures []model.ContentCapture
+	if err := query().Omit("request", "completion").
+		Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&captures).Error; err != nil {
+		return nil, 0, err
+	}
+	return captures, total, nil
+}
+
+// GetCapture 获取采集记录详情，userID 为0时不校验所属用户
+func (s *CaptureService) GetCapture(userID, id uint) (*model.ContentCapture, error) {
+	query := s.db.Where("id = ? AND expires_at > ?", id, time.Now().Unix())
+	if userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var capture model.ContentCapture
+	if err := query.First(&capture).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("采集记录不存在或已过期")
+		}
+		return nil, err
+	}
+	return &capture, nil
+}
+
+// DeleteUserCaptures 删除用户的所有采集记录
+func (s *CaptureService) DeleteUserCaptures(userID uint) (int64, error) {
+	result := s.db.Where("user_id = ?", userID).Delete(&model.ContentCapture{})
+	return result.RowsAffected, result.Error
+}
+
+// CleanupExpired 分批删除过期的采集记录
+func (s *CaptureService) CleanupExpired() (int64, error) {
+	const batchSize = 1000
+	now := time.Now().Unix()
+
+	var deleted int64
+	for {
+		result := s.db.Where("expires_at <= ?", now).Limit(batchSize).Delete(&model.ContentCapture{})
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+		if result.RowsAffected < batchSize {
+			return deleted, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// StartCleanupLoop 定时清理过期的采集记录
+func (s *CaptureService) StartCleanupLoop(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			deleted, err := s.CleanupExpired()
+			if err != nil {
+				logger.Errorf("CaptureService cleanup failed: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				logger.Infof("CaptureService cleaned up %d expired captures", deleted)
+			}
+		}
+	}()
+}
+
+// truncateUTF8 按字节截断，不截断多字节字符
+func truncateUTF8(s string, maxBytes int) (string, bool) {
+	if len(s) <= maxBytes {
+		return s, false
+	}
+	for maxBytes > 0 && !utf8.RuneStart(s[maxBytes]) {
+		maxBytes--
+	}
+	return s[:maxBytes], true
+}
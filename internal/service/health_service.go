@@ -0,0 +1,227 @@
+// internal/service/health_service.go
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"llmapisrv/config"
+	"llmapisrv/pkg/cache"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/oss"
+)
+
+// 健康检查状态
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusDegraded = "degraded" // 非必需依赖异常，仍可对外服务
+	HealthStatusFail     = "fail"     // 必需依赖异常
+)
+
+const defaultHealthTimeout = 2 * time.Second
+
+// DependencyHealth 单个依赖的检查结果
+type DependencyHealth struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Required bool   `json:"required"`
+	Latency  int64  `json:"latency_ms"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HealthReport 依赖检查汇总
+type HealthReport struct {
+	Status       string             `json:"status"`
+	CheckedAt    int64              `json:"checked_at"`
+	Dependencies []DependencyHealth `json:"dependencies"`
+}
+
+// PublicHealthComponent 状态页展示的依赖状态，不含错误信息和是否必需
+type PublicHealthComponent struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Latency int64  `json:"latency_ms"`
+}
+
+// PublicHealthReport 状态页展示的检查结果
+type PublicHealthReport struct {
+	Status     string                  `json:"status"`
+	CheckedAt  int64                   `json:"checked_at"`
+	Components []PublicHealthComponent `json:"components"`
+}
+
+// ProbeHealthComponent 就绪探针返回的依赖状态，只含名称和状态
+type ProbeHealthComponent struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// ProbeHealthReport 就绪探针返回的检查结果
+type ProbeHealthReport struct {
+	Status     string                 `json:"status"`
+	Components []ProbeHealthComponent `json:"components"`
+}
+
+type healthCheck struct {
+	name     string
+	required bool
+	check    func(ctx context.Context) error
+}
+
+type HealthService struct {
+	checks []healthCheck
+	config *config.Config
+
+	mu        sync.Mutex
+	last      *HealthReport
+	expiresAt time.Time
+}
+
+func NewHealthService(gatewayDB, newAPIDB *gorm.DB, redisCache *cache.RedisCache, newAPI *NewAPIService, ossClient *oss.OSSClient, config *config.Config) *HealthService {
+	return &HealthService{
+		checks: []healthCheck{
+			{name: "gateway_db", required: true, check: pingDB(gatewayDB)},
+			{name: "new_api_db", required: true, check: pingDB(newAPIDB)},
+			{name: "redis", required: true, check: func(ctx context.Context) error {
+				return redisCache.WithContext(ctx).Ping()
+			}},
+			{name: "new_api", required: true, check: newAPI.CheckHealth},
+			// OSS 只用于上传和归档，异常时不影响对话请求
+			{name: "oss", required: false, check: ossClient.CheckHealth},
+		},
+		config: config,
+	}
+}
+
+// Check 并发检查所有依赖，每个依赖单独超时，结果缓存 cache_seconds 秒
+func (s *HealthService) Check(ctx context.Context) *HealthReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last != nil && time.Now().Before(s.expiresAt) {
+		return s.last
+	}
+
+	report := &HealthReport{
+		Status:       HealthStatusOK,
+		CheckedAt:    time.Now().Unix(),
+		Dependencies: make([]DependencyHealth, len(s.checks)),
+	}
+
+	// 请求断开不影响正在进行的检查，结果会被其他请求复用
+	ctx = context.WithoutCancel(ctx)
+	var wg sync.WaitGroup
+	for i, c := range s.checks {
+		wg.Add(1)
+		go func(i int, c healthCheck) {
+			defer wg.Done()
+			report.Dependencies[i] = s.run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for _, dep := range report.Dependencies {
+		if dep.Status == HealthStatusOK {
+			continue
+		}
+		// 错误信息可能包含内网地址，只写入日志，不对外返回
+		logger.Warn("health check failed",
+			zap.String("dependency", dep.Name),
+			zap.Bool("required", dep.Required),
+			zap.String("error", dep.Error))
+		if dep.Required {
+			report.Status = HealthStatusFail
+			break
+		}
+		report.Status = HealthStatusDegraded
+	}
+
+	s.last = report
+	s.expiresAt = time.Now().Add(time.Duration(s.config.Health.CacheSeconds) * time.Second)
+	return report
+}
+
+func (s *HealthService) run(ctx context.Context, c healthCheck) DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout(c.name))
+	defer cancel()
+
+	// 部分客户端不感知 ctx，超时后直接返回，不等待检查结束
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	dep := DependencyHealth{
+		Name:     c.name,
+		Status:   HealthStatusOK,
+		Required: c.required,
+		Latency:  time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		dep.Status = HealthStatusFail
+		dep.Error = logger.RedactText(err.Error())
+	}
+	return dep
+}
+
+func (s *HealthService) timeout(name string) time.Duration {
+	if ms := s.config.Health.Timeouts[name]; ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	if s.config.Health.TimeoutMS > 0 {
+		return time.Duration(s.config.Health.TimeoutMS) * time.Millisecond
+	}
+	return defaultHealthTimeout
+}
+
+// Public 去掉错误信息，供公开状态页使用
+func (r *HealthReport) Public() *PublicHealthReport {
+	public := &PublicHealthReport{
+		Status:     r.Status,
+		CheckedAt:  r.CheckedAt,
+		Components: make([]PublicHealthComponent, 0, len(r.Dependencies)),
+	}
+	for _, dep := range r.Dependencies {
+		public.Components = append(public.Components, PublicHealthComponent{
+			Name:    dep.Name,
+			Status:  dep.Status,
+			Latency: dep.Latency,
+		})
+	}
+	return public
+}
+
+// Probe 只保留依赖名称和状态，供公开的就绪探针使用
+func (r *HealthReport) Probe() *ProbeHealthReport {
+	probe := &ProbeHealthReport{
+		Status:     r.Status,
+		Components: make([]ProbeHealthComponent, 0, len(r.Dependencies)),
+	}
+	for _, dep := range r.Dependencies {
+		probe.Components = append(probe.Components, ProbeHealthComponent{
+			Name:   dep.Name,
+			Status: dep.Status,
+		})
+	}
+	return probe
+}
+
+func pingDB(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
@@ -0,0 +1,406 @@
+// internal/service/log_archive_service.go
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"llmapisrv/config"
+	"llmapisrv/internal/model"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/oss"
+)
+
+// ArchivedLog 归档行，补充 model.Log 中不对外输出的字段
+type ArchivedLog struct {
+	model.Log
+	Username  string `json:"username"`
+	TokenName string `json:"token_name"`
+}
+
+// ArchiveManifest 每天一个清单文件，列出当天的所有归档文件
+type ArchiveManifest struct {
+	Day       string             `json:"day"`
+	RowCount  int64              `json:"row_count"`
+	Files     []model.LogArchive `json:"files"`
+	UpdatedAt int64              `json:"updated_at"`
+}
+
+// ArchiveQuery 查询归档日志的条件
+type ArchiveQuery struct {
+	UserID uint
+	Model  string
+	Limit  int
+}
+
+// LogArchiveService 日志归档，过期日志按天写成 gzip 压缩的 JSONL 上传到 OSS 后再分批删除
+// 文件路径：<prefix>/dt=2006-01-02/logs_<min_id>_<max_id>.jsonl.gz，清单：<prefix>/dt=2006-01-02/manifest.json
+type LogArchiveService struct {
+	db     *gorm.DB
+	oss    *oss.OSSClient
+	config *config.Config
+}
+
+func NewLogArchiveService(db *gorm.DB, ossClient *oss.OSSClient, config *config.Config) *LogArchiveService {
+	return &LogArchiveService{
+		db:     db,
+		oss:    ossClient,
+		config: config,
+	}
+}
+
+// ArchiveBefore 归档并删除 cutoff 之前的日志，cutoff 应为某天零点
+func (s *LogArchiveService) ArchiveBefore(ctx context.Context, cutoff time.Time) error {
+	var minCreatedAt *int64
+	if err := s.db.Model(&model.Log{}).
+		Where("created_at < ?", cutoff.Unix()).
+		Select("MIN(created_at)").
+		Scan(&minCreatedAt).Error; err != nil {
+		return err
+	}
+	if minCreatedAt == nil {
+		return nil
+	}
+
+	for day := dayStart(time.Unix(*minCreatedAt, 0)); day.Before(cutoff); day = day.AddDate(0, 0, 1) {
+		archive, err := s.ArchiveDay(ctx, day)
+		if err != nil {
+			return fmt.Errorf("archive %s failed: %w", day.Format(StatsDayFormat), err)
+		}
+		if archive != nil {
+			logger.Infof("Archived %d logs of %s to %s", archive.RowCount, archive.Day, archive.ObjectKey)
+		}
+
+		// 只删除已归档的行（包括恢复后的行），归档之后才同步到的日志留到下次归档
+		maxID, err := s.archivedMaxID(day.Format(StatsDayFormat))
+		if err != nil {
+			return err
+		}
+		if maxID == 0 {
+			continue
+		}
+		if err := deleteLogsInBatches(s.db, s.config.Log.DeleteBatchSize,
+			"created_at >= ? AND created_at < ? AND id <= ?",
+			day.Unix(), day.AddDate(0, 0, 1).Unix(), maxID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archivedMaxID 某天已归档的最大日志ID
+func (s *LogArchiveService) archivedMaxID(day string) (uint, error) {
+	var maxID uint
+	err := s.db.Model(&model.LogArchive{}).
+		Where("day = ?", day).
+		Select("COALESCE(MAX(max_id), 0)").
+		Scan(&maxID).Error
+	return maxID, err
+}
+
+// ArchiveDay 将某天尚未归档的日志写成一个归档文件，没有新日志时返回 nil
+// 已恢复的日志 ID 不超过已有归档的最大 ID，不会重复归档
+func (s *LogArchiveService) ArchiveDay(ctx context.Context, day time.Time) (*model.LogArchive, error) {
+	dayStr := day.Format(StatsDayFormat)
+
+	archivedMaxID, err := s.archivedMaxID(dayStr)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "log-archive-*.jsonl.gz")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	archive, err := s.writeDay(tmp, day, archivedMaxID)
+	if err != nil || archive == nil {
+		return nil, err
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	archive.ObjectKey = path.Join(s.dayDir(dayStr), fmt.Sprintf("logs_%d_%d.jsonl.gz", archive.MinID, archive.MaxID))
+	if err := s.oss.UploadFile(ctx, archive.ObjectKey, tmp); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(archive).Error; err != nil {
+		return nil, err
+	}
+	if err := s.writeManifest(ctx, dayStr); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// writeDay 逐行读取日志写入压缩文件，同时计算校验和
+func (s *LogArchiveService) writeDay(f *os.File, day time.Time, afterID uint) (*model.LogArchive, error) {
+	rows, err := s.db.Model(&model.Log{}).
+		Where("created_at >= ? AND created_at < ? AND id > ?", day.Unix(), day.AddDate(0, 0, 1).Unix(), afterID).
+		Order("id ASC").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(f, hash)}
+	gz := gzip.NewWriter(counter)
+	encoder := json.NewEncoder(gz)
+
+	archive := &model.LogArchive{
+		Day:       day.Format(StatsDayFormat),
+		CreatedAt: time.Now(),
+	}
+	for rows.Next() {
+		var log model.Log
+		if err := s.db.ScanRows(rows, &log); err != nil {
+			return nil, err
+		}
+		if err := encoder.Encode(ArchivedLog{Log: log, Username: log.Username, TokenName: log.TokenName}); err != nil {
+			return nil, err
+		}
+		if archive.RowCount == 0 {
+			archive.MinID = log.ID
+		}
+		archive.MaxID = log.ID
+		archive.RowCount++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if archive.RowCount == 0 {
+		return nil, nil
+	}
+
+	archive.Size = counter.n
+	archive.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return archive, nil
+}
+
+// writeManifest 根据归档记录重写当天的清单文件
+func (s *LogArchiveService) writeManifest(ctx context.Context, day string) error {
+	files, err := s.dayArchives(day)
+	if err != nil {
+		return err
+	}
+
+	manifest := ArchiveManifest{
+		Day:       day,
+		Files:     files,
+		UpdatedAt: time.Now().Unix(),
+	}
+	for _, f := range files {
+		manifest.RowCount += f.RowCount
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.oss.UploadFile(ctx, path.Join(s.dayDir(day), "manifest.json"), bytes.NewReader(data))
+}
+
+// ListArchives 按日期分页列出归档
+func (s *LogArchiveService) ListArchives(page, pageSize int) ([]model.LogArchive, int64, error) {
+	var archives []model.LogArchive
+	var total int64
+
+	if err := s.db.Model(&model.LogArchive{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := s.db.Order("day DESC, id ASC").
+		Limit(pageSize).
+		Offset(offset).
+		Find(&archives).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return archives, total, nil
+}
+
+// QueryDay 从归档文件中查询某天的日志，按条件过滤后最多返回 Limit 行
+func (s *LogArchiveService) QueryDay(ctx context.Context, day string, query ArchiveQuery) ([]ArchivedLog, error) {
+	result := []ArchivedLog{}
+	err := s.scanDay(ctx, day, func(log *ArchivedLog) (bool, error) {
+		if query.UserID > 0 && log.UserID != query.UserID {
+			return true, nil
+		}
+		if query.Model != "" && log.ModelName != query.Model {
+			return true, nil
+		}
+		result = append(result, *log)
+		return len(result) < query.Limit, nil
+	})
+	return result, err
+}
+
+// RestoreDay 将某天的归档日志恢复到日志表，已存在的行跳过，返回恢复的行数
+// 恢复的日志仍早于保留期限，下次清理时会再次删除（不会重复归档）
+func (s *LogArchiveService) RestoreDay(ctx context.Context, day string) (int64, error) {
+	const batchSize = 500
+
+	var restored int64
+	batch := make([]model.Log, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&batch)
+		if result.Error != nil {
+			return result.Error
+		}
+		restored += result.RowsAffected
+		batch = batch[:0]
+		return nil
+	}
+
+	err := s.scanDay(ctx, day, func(log *ArchivedLog) (bool, error) {
+		row := log.Log
+		row.Username = log.Username
+		row.TokenName = log.TokenName
+		batch = append(batch, row)
+		if len(batch) >= batchSize {
+			return true, flush()
+		}
+		return true, nil
+	})
+	if err != nil {
+		return restored, err
+	}
+	return restored, flush()
+}
+
+// scanDay 依次读取某天的归档文件并校验，fn 返回 false 时停止
+func (s *LogArchiveService) scanDay(ctx context.Context, day string, fn func(log *ArchivedLog) (bool, error)) error {
+	files, err := s.dayArchives(day)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no archive found for %s", day)
+	}
+
+	for _, f := range files {
+		next, err := s.scanFile(ctx, f, fn)
+		if err != nil {
+			return fmt.Errorf("read archive %s failed: %w", f.ObjectKey, err)
+		}
+		if !next {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *LogArchiveService) scanFile(ctx context.Context, archive model.LogArchive, fn func(log *ArchivedLog) (bool, error)) (bool, error) {
+	body, err := s.oss.DownloadFile(ctx, archive.ObjectKey)
+	if err != nil {
+		return false, err
+	}
+	defer body.Close()
+
+	hash := sha256.New()
+	gz, err := gzip.NewReader(io.TeeReader(body, hash))
+	if err != nil {
+		return false, err
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var log ArchivedLog
+		if err := json.Unmarshal(scanner.Bytes(), &log); err != nil {
+			return false, err
+		}
+		next, err := fn(&log)
+		if err != nil || !next {
+			return false, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+
+	// 完整读取后校验文件
+	io.Copy(io.Discard, gz)
+	if sum := hex.EncodeToString(hash.Sum(nil)); archive.SHA256 != "" && sum != archive.SHA256 {
+		return false, fmt.Errorf("checksum mismatch")
+	}
+	return true, nil
+}
+
+func (s *LogArchiveService) dayArchives(day string) ([]model.LogArchive, error) {
+	var files []model.LogArchive
+	if err := s.db.Where("day = ?", day).Order("id ASC").Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (s *LogArchiveService) dayDir(day string) string {
+	prefix := s.config.Log.ArchivePrefix
+	if prefix == "" {
+		prefix = "log-archive"
+	}
+	return path.Join(prefix, "dt="+day)
+}
+
+// deleteLogsInBatches 分批删除日志，每批单独提交，避免长时间锁表
+func deleteLogsInBatches(db *gorm.DB, batchSize int, query string, args ...interface{}) error {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	for {
+		result := db.Where(query, args...).Limit(batchSize).Delete(&model.Log{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < int64(batchSize) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// dayStart 当天零点（服务器时区）
+func dayStart(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
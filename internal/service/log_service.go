@@ -2,26 +2,38 @@
 package service
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
 	"time"
 
 	"gorm.io/gorm"
 
 	"llmapisrv/config"
 	"llmapisrv/internal/model"
+	"llmapisrv/pkg/export"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/money"
 )
 
 type LogService struct {
 	gatewayDB *gorm.DB
 	newAPIDB  *gorm.DB
 	config    *config.Config
+	archiver  *LogArchiveService
+	records   *RequestRecordService
 }
 
-func NewLogService(gatewayDB, newAPIDB *gorm.DB, config *config.Config) *LogService {
+func NewLogService(gatewayDB, newAPIDB *gorm.DB, config *config.Config, archiver *LogArchiveService, records *RequestRecordService) *LogService {
 	return &LogService{
 		gatewayDB: gatewayDB,
 		newAPIDB:  newAPIDB,
 		config:    config,
+		archiver:  archiver,
+		records:   records,
 	}
 }
 
@@ -30,20 +42,147 @@ func (s *LogService) SaveLog(log *model.Log) error {
 	return s.gatewayDB.Create(log).Error
 }
 
-// GetLogsByUserID 获取用户日志（从本地数据库）
-func (s *LogService) GetLogsByUserID(userID uint, page, pageSize int) ([]model.Log, int64, error) {
+// LogFilter 日志查询条件，零值表示不过滤
+type LogFilter struct {
+	StartTime int64  // 开始时间戳（含）
+	EndTime   int64  // 结束时间戳（不含）
+	Model     string // 模型名
+	IsStream  *bool
+	Type      *int
+	MinQuota  int64 // 最低消耗额度
+}
+
+// ParseLogFilter 解析日志过滤参数：start_time、end_time（时间戳）、model、is_stream、type、min_cost（美元）
+func ParseLogFilter(query url.Values) (LogFilter, error) {
+	var filter LogFilter
+	var err error
+
+	if v := query.Get("start_time"); v != "" {
+		if filter.StartTime, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return filter, fmt.Errorf("invalid start_time")
+		}
+	}
+	if v := query.Get("end_time"); v != "" {
+		if filter.EndTime, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return filter, fmt.Errorf("invalid end_time")
+		}
+	}
+	if filter.StartTime > 0 && filter.EndTime > 0 && filter.StartTime >= filter.EndTime {
+		return filter, fmt.Errorf("start_time must be before end_time")
+	}
+
+	filter.Model = query.Get("model")
+
+	if v := query.Get("is_stream"); v != "" {
+		isStream, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid is_stream")
+		}
+		filter.IsStream = &isStream
+	}
+	if v := query.Get("type"); v != "" {
+		logType, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid type")
+		}
+		filter.Type = &logType
+	}
+	if v := query.Get("min_cost"); v != "" {
+		minCost, err := strconv.ParseFloat(v, 64)
+		if err != nil || minCost < 0 {
+			return filter, fmt.Errorf("invalid min_cost")
+		}
+		filter.MinQuota = money.USDToQuota(minCost)
+	}
+
+	return filter, nil
+}
+
+// LogCursor 游标分页位置，按 (created_at, id) 倒序
+type LogCursor struct {
+	CreatedAt int64
+	ID        uint
+}
+
+// Encode 编码为不透明的游标字符串
+func (c *LogCursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", c.CreatedAt, c.ID)))
+}
+
+// DecodeLogCursor 解析游标字符串，空字符串表示第一页
+func DecodeLogCursor(s string) (*LogCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var cursor LogCursor
+	if _, err := fmt.Sscanf(string(data), "%d:%d", &cursor.CreatedAt, &cursor.ID); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &cursor, nil
+}
+
+// LogStat 日志汇总统计
+type LogStat struct {
+	Key              string       `json:"key,omitempty"` // 模型名或日期
+	Count            int64        `json:"count"`
+	Quota            int64        `json:"quota"`
+	PromptTokens     int64        `json:"prompt_tokens"`
+	CompletionTokens int64        `json:"completion_tokens"`
+	Amount           money.Amount `json:"amount" gorm:"-"`
+}
+
+// LogSummary 按模型和按天的汇总
+type LogSummary struct {
+	Total   LogStat   `json:"total"`
+	ByModel []LogStat `json:"by_model"`
+	ByDay   []LogStat `json:"by_day"`
+}
+
+// filterLogs 构建用户日志的查询条件
+func (s *LogService) filterLogs(userID uint, filter LogFilter) *gorm.DB {
+	return applyLogFilter(s.gatewayDB.Model(&model.Log{}).Where("user_id = ?", userID), filter)
+}
+
+func applyLogFilter(query *gorm.DB, filter LogFilter) *gorm.DB {
+	if filter.StartTime > 0 {
+		query = query.Where("created_at >= ?", filter.StartTime)
+	}
+	if filter.EndTime > 0 {
+		query = query.Where("created_at < ?", filter.EndTime)
+	}
+	if filter.Model != "" {
+		query = query.Where("model_name = ?", filter.Model)
+	}
+	if filter.IsStream != nil {
+		query = query.Where("is_stream = ?", *filter.IsStream)
+	}
+	if filter.Type != nil {
+		query = query.Where("type = ?", *filter.Type)
+	}
+	if filter.MinQuota > 0 {
+		query = query.Where("quota >= ?", filter.MinQuota)
+	}
+	return query
+}
+
+// GetLogsByUserID 获取用户日志（从本地数据库），按页码分页
+func (s *LogService) GetLogsByUserID(userID uint, filter LogFilter, page, pageSize int) ([]model.Log, int64, error) {
 	var logs []model.Log
 	var total int64
 
 	// 计算总数
-	if err := s.gatewayDB.Model(&model.Log{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+	if err := s.filterLogs(userID, filter).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
 	// 分页查询
 	offset := (page - 1) * pageSize
-	if err := s.gatewayDB.Where("user_id = ?", userID).
-		Order("created_at DESC").
+	if err := s.filterLogs(userID, filter).
+		Order("created_at DESC, id DESC").
 		Limit(pageSize).
 		Offset(offset).
 		Find(&logs).Error; err != nil {
@@ -53,7 +192,150 @@ func (s *LogService) GetLogsByUserID(userID uint, page, pageSize int) ([]model.L
 	return logs, total, nil
 }
 
-// CleanupOldLogs 清理旧日志（仅清理本地数据库）
+// GetLogsByCursor 按游标分页获取用户日志，不统计总数，返回下一页游标，没有更多数据时为 nil
+func (s *LogService) GetLogsByCursor(userID uint, filter LogFilter, cursor *LogCursor, limit int) ([]model.Log, *LogCursor, error) {
+	var logs []model.Log
+
+	query := s.filterLogs(userID, filter)
+	if cursor != nil {
+		query = query.Where("created_at < ? OR (created_at = ? AND id < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+
+	// 多取一条判断是否还有下一页
+	if err := query.Order("created_at DESC, id DESC").
+		Limit(limit + 1).
+		Find(&logs).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if len(logs) <= limit {
+		return logs, nil, nil
+	}
+	logs = logs[:limit]
+	last := logs[len(logs)-1]
+	return logs, &LogCursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
+}
+
+// 按天汇总时先按15分钟分桶，所有时区的偏移都是15分钟的整数倍，每个桶只属于一天
+const logSummaryBucketSeconds = 900
+
+// GetLogSummary 汇总用户日志，按模型和按天分组，日期按 loc 时区划分（与 ExportLogs 一致）
+func (s *LogService) GetLogSummary(userID uint, filter LogFilter, loc *time.Location) (*LogSummary, error) {
+	const fields = "COUNT(*) AS count, COALESCE(SUM(quota), 0) AS quota, " +
+		"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(completion_tokens), 0) AS completion_tokens"
+
+	summary := &LogSummary{}
+	if err := s.filterLogs(userID, filter).Select(fields).Scan(&summary.Total).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.filterLogs(userID, filter).
+		Select("model_name AS `key`, " + fields).
+		Group("model_name").
+		Order("quota DESC").
+		Scan(&summary.ByModel).Error; err != nil {
+		return nil, err
+	}
+
+	// 数据库只按时间戳分桶，日期在这里按调用方时区换算，不依赖数据库的时区和日期函数
+	var buckets []struct {
+		Bucket           int64
+		Count            int64
+		Quota            int64
+		PromptTokens     int64
+		CompletionTokens int64
+	}
+	if err := s.filterLogs(userID, filter).
+		Select(fmt.Sprintf("created_at - created_at %% %d AS bucket, %s", logSummaryBucketSeconds, fields)).
+		Group("bucket").
+		Order("bucket ASC").
+		Scan(&buckets).Error; err != nil {
+		return nil, err
+	}
+	for _, b := range buckets {
+		day := time.Unix(b.Bucket, 0).In(loc).Format("2006-01-02")
+		if n := len(summary.ByDay); n == 0 || summary.ByDay[n-1].Key != day {
+			summary.ByDay = append(summary.ByDay, LogStat{Key: day})
+		}
+		stat := &summary.ByDay[len(summary.ByDay)-1]
+		stat.Count += b.Count
+		stat.Quota += b.Quota
+		stat.PromptTokens += b.PromptTokens
+		stat.CompletionTokens += b.CompletionTokens
+	}
+
+	summary.Total.Amount = money.FromQuota(summary.Total.Quota)
+	for i := range summary.ByModel {
+		summary.ByModel[i].Amount = money.FromQuota(summary.ByModel[i].Quota)
+	}
+	for i := range summary.ByDay {
+		summary.ByDay[i].Amount = money.FromQuota(summary.ByDay[i].Quota)
+	}
+	if summary.ByModel == nil {
+		summary.ByModel = []LogStat{}
+	}
+	if summary.ByDay == nil {
+		summary.ByDay = []LogStat{}
+	}
+
+	return summary, nil
+}
+
+// ExportLogs 逐行导出日志，userID 为0时导出所有用户，时间按 loc 时区格式化
+func (s *LogService) ExportLogs(userID uint, filter LogFilter, loc *time.Location, w export.RowWriter) error {
+	query := s.gatewayDB.Model(&model.Log{})
+	if userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	rows, err := applyLogFilter(query, filter).
+		Select("id", "user_id", "api_key_id", "created_at", "type", "model_name", "upstream_model_name",
+			"is_stream", "prompt_tokens", "completion_tokens", "quota", "use_time").
+		Order("created_at ASC, id ASC").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if err := w.Write([]interface{}{
+		"id", "time", "user_id", "api_key_id", "type", "model", "upstream_model", "is_stream",
+		"prompt_tokens", "completion_tokens", "quota", "cost", "currency", "use_time",
+	}); err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var log model.Log
+		if err := s.gatewayDB.ScanRows(rows, &log); err != nil {
+			return err
+		}
+		if err := w.Write([]interface{}{
+			log.ID,
+			time.Unix(log.CreatedAt, 0).In(loc).Format("2006-01-02 15:04:05"),
+			log.UserID,
+			log.APIKeyID,
+			log.Type,
+			log.ModelName,
+			log.UpstreamModelName,
+			log.IsStream,
+			log.PromptTokens,
+			log.CompletionTokens,
+			log.Quota,
+			money.QuotaToDisplay(log.Quota),
+			money.Currency(),
+			log.UseTime,
+		}); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// CleanupOldLogs 清理旧日志（仅清理本地数据库），开启归档时先归档到 OSS 再删除
 func (s *LogService) CleanupOldLogs() error {
 	// 计算保留期限
 	retentionDays := s.config.Log.RetentionDays
@@ -61,10 +343,15 @@ func (s *LogService) CleanupOldLogs() error {
 		retentionDays = 30 // 默认30天
 	}
 
-	cutoffTime := time.Now().AddDate(0, 0, -retentionDays).Unix()
+	// 按整天清理，便于按天归档
+	cutoff := dayStart(time.Now().AddDate(0, 0, -retentionDays))
+
+	if s.config.Log.ArchiveEnabled {
+		return s.archiver.ArchiveBefore(context.Background(), cutoff)
+	}
 
-	// 删除旧日志
-	return s.gatewayDB.Where("created_at < ?", cutoffTime).Delete(&model.Log{}).Error
+	// 分批删除旧日志
+	return deleteLogsInBatches(s.gatewayDB, s.config.Log.DeleteBatchSize, "created_at < ?", cutoff.Unix())
 }
 
 // ProcessLogFromQueue 从队列处理日志
@@ -89,19 +376,41 @@ func (s *LogService) ProcessLogFromQueue(data []byte, syncSrv *SyncService) erro
 	var syncState model.SyncState
 	if err := s.gatewayDB.Model(&model.SyncState{}).
 		Where("token_id = ?", user.TokenID).
-		First(&syncState).Error; err != nil {
+		First(&syncState).Error; err != nil && err != gorm.ErrRecordNotFound {
 		return err
 	}
 
 	// 同步日志
 	syncSrv.SyncLogsByTokenID(user.TokenID, uint(syncState.LastSyncID))
 
+	// 将网关调用记录关联到本次同步到的日志，子密钥调用按调用记录归属
+	if traceID, _ := logData["trace_id"].(string); traceID != "" {
+		record, err := s.records.Link(user.ID, traceID, syncState.LastSyncID)
+		if err != nil {
+			if err != gorm.ErrRecordNotFound {
+				logger.Errorf("link request record failed, trace_id: %v, err: %v", traceID, err)
+			}
+		} else if record.APIKeyID > 0 && record.LogID > 0 {
+			if err := s.attributeSubKeyLog(record); err != nil {
+				logger.Errorf("attribute sub key log failed, api_key_id: %v, err: %v", record.APIKeyID, err)
+			}
+		}
+	}
+
 	// 同步额度
 	syncSrv.SyncUserByAPIKey(apiKey)
 
 	return nil
 }
 
+// attributeSubKeyLog 将调用记录关联的日志标记为子密钥调用，仅用于日志和统计展示
+// 子密钥的已用额度和消费计数在请求结束时按网关记录的用量累加，不依赖日志匹配
+func (s *LogService) attributeSubKeyLog(record *model.RequestRecord) error {
+	return s.gatewayDB.Model(&model.Log{}).
+		Where("id = ? AND api_key_id = 0", record.LogID).
+		Update("api_key_id", record.APIKeyID).Error
+}
+
 // GetLatestRemoteLogID 获取最新的远程日志ID
 func (s *LogService) GetLatestRemoteLogID(tokenID uint) (uint, error) {
 	var syncState model.SyncState
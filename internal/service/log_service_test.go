@@ -0,0 +1,65 @@
+// internal/service/log_service_test.go
+package service
+
+import (
+	"testing"
+	"time"
+
+	"llmapisrv/config"
+	"llmapisrv/internal/model"
+)
+
+func TestGetLogSummaryByDayUsesLocation(t *testing.T) {
+	db := openTestDB(t, "gateway")
+	if err := db.AutoMigrate(&model.Log{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	svc := NewLogService(db, nil, &config.Config{}, nil, nil)
+
+	// 2026-01-01 15:30 UTC 即上海时间 2026-01-01 23:30，16:30 UTC 即上海时间 2026-01-02 00:30
+	base := time.Date(2026, 1, 1, 15, 30, 0, 0, time.UTC).Unix()
+	for i, createdAt := range []int64{base, base + 3600} {
+		log := model.Log{UserID: 1, RemoteLogID: uint(i + 1), CreatedAt: createdAt, ModelName: "gpt-4o", Quota: 100}
+		if err := db.Create(&log).Error; err != nil {
+			t.Fatalf("create log: %v", err)
+		}
+	}
+
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skipf("load Asia/Shanghai: %v", err)
+	}
+	tests := []struct {
+		loc  *time.Location
+		want map[string]int64
+	}{
+		{time.UTC, map[string]int64{"2026-01-01": 2}},
+		{shanghai, map[string]int64{"2026-01-01": 1, "2026-01-02": 1}},
+	}
+	for _, tt := range tests {
+		summary, err := svc.GetLogSummary(1, LogFilter{}, tt.loc)
+		if err != nil {
+			t.Fatalf("%s: summary: %v", tt.loc, err)
+		}
+		got := map[string]int64{}
+		for _, stat := range summary.ByDay {
+			got[stat.Key] = stat.Count
+			if stat.Quota != stat.Count*100 {
+				t.Errorf("%s: %s quota = %d, want %d", tt.loc, stat.Key, stat.Quota, stat.Count*100)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: by_day = %v, want %v", tt.loc, got, tt.want)
+			continue
+		}
+		for day, count := range tt.want {
+			if got[day] != count {
+				t.Errorf("%s: by_day = %v, want %v", tt.loc, got, tt.want)
+				break
+			}
+		}
+		if summary.Total.Count != 2 {
+			t.Errorf("%s: total count = %d, want 2", tt.loc, summary.Total.Count)
+		}
+	}
+}
@@ -7,19 +7,22 @@ import (
 	"gorm.io/gorm"
 
 	"llmapisrv/config"
+	"llmapisrv/pkg/money"
 )
 
 type ModelService struct {
-	gatewayDB *gorm.DB
-	newAPIDB  *gorm.DB
-	config    *config.Config
+	gatewayDB     *gorm.DB
+	newAPIDB      *gorm.DB
+	newAPIService *NewAPIService
+	config        *config.Config
 }
 
-func NewModelService(gatewayDB, newAPIDB *gorm.DB, config *config.Config) *ModelService {
+func NewModelService(gatewayDB, newAPIDB *gorm.DB, newAPIService *NewAPIService, config *config.Config) *ModelService {
 	return &ModelService{
-		gatewayDB: gatewayDB,
-		newAPIDB:  newAPIDB,
-		config:    config,
+		gatewayDB:     gatewayDB,
+		newAPIDB:      newAPIDB,
+		newAPIService: newAPIService,
+		config:        config,
 	}
 }
 
@@ -79,9 +82,8 @@ func (s *ModelService) MapModels(models []interface{}) []interface{} {
 
 // CalculateQuota 计算使用额度
 func (s *ModelService) CalculateQuota(modelName string, promptTokens, completionTokens int) (int64, error) {
-	// 获取模型价格信息
-	newAPIService := NewNewAPIService(s.config, nil)
-	pricing, err := newAPIService.GetModelPricing()
+	// 获取模型价格信息（缓存1小时）
+	pricing, err := s.newAPIService.GetModelPricing()
 	if err != nil {
 		return 0, err
 	}
@@ -138,10 +140,8 @@ func (s *ModelService) CalculateQuota(modelName string, promptTokens, completion
 	inputCost := float64(promptTokens) / 1000000.0 * 2.0 * modelRatio
 	outputCost := float64(completionTokens) / 1000000.0 * 2.0 * modelRatio * completionRatio
 
-	// 总价格（单位：0.001美元）
-	totalCost := (inputCost + outputCost) * 1000.0
-
-	return int64(totalCost), nil
+	// 美元价格换算为额度
+	return money.USDToQuota(inputCost + outputCost), nil
 }
 
 // 检查模型状态并更新
@@ -3,15 +3,22 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
 	"llmapisrv/config"
 	"llmapisrv/pkg/cache"
 	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/tracing"
 )
 
 type NewAPIService struct {
@@ -120,19 +127,57 @@ func (s *NewAPIService) GetModelPricing() (map[string]interface{}, error) {
 	return result, nil
 }
 
+// CheckHealth 检查 New API 是否可访问
+func (s *NewAPIService) CheckHealth(ctx context.Context) error {
+	url := fmt.Sprintf("%s/api/status", s.config.NewAPI.Domain)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API status: %s", resp.Status)
+	}
+	return nil
+}
+
+// UpstreamCall 一次转发的上游信息
+type UpstreamCall struct {
+	Model       string        // 映射后实际调用的模型
+	Attempts    int           // 上游请求次数，0为未转发
+	ConnectTime time.Duration // 获取上游连接的耗时，复用连接时接近0
+}
+
 // 转发聊天完成请求
 func (s *NewAPIService) ChatCompletion(apiKey string, requestBody map[string]interface{}) (*http.Response, error) {
+	resp, _, err := s.ChatCompletionWithCall(context.Background(), apiKey, requestBody)
+	return resp, err
+}
+
+// ChatCompletionWithCall 转发聊天完成请求，同时返回实际调用的模型和请求次数
+// 请求头带上 ctx 中的 traceparent，span 在响应体关闭时结束
+func (s *NewAPIService) ChatCompletionWithCall(ctx context.Context, apiKey string, requestBody map[string]interface{}) (*http.Response, *UpstreamCall, error) {
+	call := &UpstreamCall{}
+
 	// 获取请求的模型
 	modelName, ok := requestBody["model"].(string)
 	if !ok {
-		return nil, fmt.Errorf("missing model parameter")
+		return nil, call, fmt.Errorf("missing model parameter")
 	}
 
 	// 查找可用的实际模型
-	actualModel, err := s.getAvailableModel(modelName)
+	actualModel, err := s.getAvailableModel(ctx, modelName)
 	if err != nil {
-		return nil, err
+		return nil, call, err
 	}
+	call.Model = actualModel
 
 	// 替换模型名称
 	requestBody["model"] = actualModel
@@ -140,24 +185,54 @@ func (s *NewAPIService) ChatCompletion(apiKey string, requestBody map[string]int
 	// 构建请求
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return nil, err
+		return nil, call, err
 	}
 
 	url := fmt.Sprintf("%s/v1/chat/completions", s.config.NewAPI.Domain)
-	logger.Infof("ChatCompletion url: %v, requestBody: %v", url, string(jsonData))
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	logger.Infof("ChatCompletion url: %v, model: %v, body size: %d", url, actualModel, len(jsonData))
+	ctx, span := tracing.Start(ctx, "newapi.chat_completions",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", http.MethodPost),
+			attribute.String("server.address", s.config.NewAPI.Domain),
+			attribute.String("llm.model", modelName),
+			attribute.String("llm.upstream_model", actualModel),
+		),
+	)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return nil, err
+		tracing.End(span, err)
+		return nil, call, err
 	}
+	tracing.Inject(ctx, req.Header)
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 
-	return s.client.Do(req)
+	// 记录获取连接（DNS、TCP、TLS）的耗时
+	var connectStart time.Time
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GetConn: func(string) { connectStart = time.Now() },
+		GotConn: func(httptrace.GotConnInfo) { call.ConnectTime = time.Since(connectStart) },
+	}))
+
+	call.Attempts++
+	resp, err := s.client.Do(req)
+	span.SetAttributes(attribute.Int64("upstream.connect_ms", call.ConnectTime.Milliseconds()))
+	if err != nil {
+		tracing.End(span, err)
+		return nil, call, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	resp.Body = tracing.EndOnClose(resp.Body, span)
+	return resp, call, nil
 }
 
 // 获取可用模型
-func (s *NewAPIService) getAvailableModel(modelName string) (string, error) {
+func (s *NewAPIService) getAvailableModel(ctx context.Context, modelName string) (string, error) {
 	// 从映射配置中查找
 	models, ok := s.config.ModelMapping[modelName]
 	if !ok {
@@ -167,7 +242,7 @@ func (s *NewAPIService) getAvailableModel(modelName string) (string, error) {
 	// 检查缓存中的可用模型状态
 	for _, model := range models {
 		cacheKey := fmt.Sprintf("model:status:%s", model)
-		status, err := s.cache.Get(cacheKey)
+		status, err := s.cache.WithContext(ctx).Get(cacheKey)
 		if err == nil && status == "available" {
 			return model, nil
 		}
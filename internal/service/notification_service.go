@@ -0,0 +1,594 @@
+// internal/service/notification_service.go
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"llmapisrv/config"
+	"llmapisrv/internal/model"
+	"llmapisrv/pkg/cache"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/money"
+	"llmapisrv/pkg/notifier"
+	"llmapisrv/pkg/queue"
+	"llmapisrv/pkg/webhook"
+)
+
+// 通知事件类型
+const (
+	EventBalanceLow      = "balance.low"      // 余额低于阈值
+	EventAccountExpiring = "account.expiring" // 账户即将到期
+	EventTest            = "test"             // 测试事件
+)
+
+// webhook 投递状态
+const (
+	DeliveryStatusPending = 0
+	DeliveryStatusSuccess = 1
+	DeliveryStatusFailed  = 2
+)
+
+// WebhookQueue webhook 投递队列
+const WebhookQueue = "webhook:deliver"
+
+const (
+	deliveryLeaseSeconds = 300 // 投递租约，超时未完成的投递由重试任务重新入队
+	notifyDedupTTL       = 30 * 86400
+)
+
+// 第 n 次投递失败后等待 webhookRetryBackoff[n-1] 秒再重试
+var webhookRetryBackoff = []int64{60, 300, 1800, 7200, 21600}
+
+// NotificationSettingsParams 通知设置参数
+type NotificationSettingsParams struct {
+	WebhookURL        string
+	BalanceThresholds []int64 // 余额阈值（额度）
+	ExpiryDays        []int
+	Email             string
+	Language          string
+	Enabled           bool
+}
+
+// WebhookEvent 投递的事件内容
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt int64       `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// NotificationSettingsView 通知设置展示结构，余额阈值按金额展示
+type NotificationSettingsView struct {
+	WebhookURL        string         `json:"webhook_url"`
+	Secret            string         `json:"secret"`
+	BalanceThresholds []money.Amount `json:"balance_thresholds"`
+	ExpiryDays        []int          `json:"expiry_days"`
+	Email             string         `json:"email"`
+	Language          string         `json:"language"`
+	Enabled           bool           `json:"enabled"`
+}
+
+// NewNotificationSettingsView 构建通知设置展示结构
+func NewNotificationSettingsView(setting *model.NotificationSetting) *NotificationSettingsView {
+	view := &NotificationSettingsView{
+		WebhookURL:        setting.WebhookURL,
+		Secret:            setting.Secret,
+		BalanceThresholds: []money.Amount{},
+		ExpiryDays:        parseInts(setting.ExpiryDays),
+		Email:             setting.Email,
+		Language:          setting.Language,
+		Enabled:           setting.Enabled,
+	}
+	for _, t := range parseInt64s(setting.BalanceThresholds) {
+		view.BalanceThresholds = append(view.BalanceThresholds, money.FromQuota(t))
+	}
+	if view.ExpiryDays == nil {
+		view.ExpiryDays = []int{}
+	}
+	return view
+}
+
+type NotificationService struct {
+	db       *gorm.DB
+	cache    *cache.RedisCache
+	queue    *queue.RedisQueue
+	config   *config.Config
+	client   *webhook.Client
+	notifier *notifier.Notifier
+}
+
+func NewNotificationService(db *gorm.DB, cache *cache.RedisCache, queue *queue.RedisQueue, config *config.Config, notifier *notifier.Notifier) *NotificationService {
+	timeout := config.Notification.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = 10
+	}
+	return &NotificationService{
+		db:       db,
+		cache:    cache,
+		queue:    queue,
+		config:   config,
+		client:   webhook.NewClient(time.Duration(timeout)*time.Second, config.Notification.AllowPrivateTargets),
+		notifier: notifier,
+	}
+}
+
+// GetSettings 获取用户的通知设置，未设置时返回空设置
+func (s *NotificationService) GetSettings(userID uint) (*model.NotificationSetting, error) {
+	var setting model.NotificationSetting
+	err := s.db.Where("user_id = ?", userID).First(&setting).Error
+	if err == gorm.ErrRecordNotFound {
+		return &model.NotificationSetting{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
+
+// UpdateSettings 保存通知设置，首次保存时生成签名密钥
+func (s *NotificationService) UpdateSettings(userID uint, params NotificationSettingsParams) (*model.NotificationSetting, error) {
+	if params.WebhookURL != "" {
+		if err := webhook.ValidateURL(params.WebhookURL); err != nil {
+			return nil, err
+		}
+	}
+	if params.Email != "" {
+		if _, err := mail.ParseAddress(params.Email); err != nil {
+			return nil, fmt.Errorf("invalid email address")
+		}
+	}
+	if params.Enabled && params.WebhookURL == "" && params.Email == "" {
+		return nil, fmt.Errorf("webhook_url or email is required")
+	}
+	if params.Language != "" && params.Language != notifier.LangZH && params.Language != notifier.LangEN {
+		return nil, fmt.Errorf("language must be zh or en")
+	}
+	for _, t := range params.BalanceThresholds {
+		if t <= 0 {
+			return nil, fmt.Errorf("balance threshold must be positive")
+		}
+	}
+	for _, d := range params.ExpiryDays {
+		if d <= 0 || d > 365 {
+			return nil, fmt.Errorf("expiry days must be between 1 and 365")
+		}
+	}
+
+	setting, err := s.GetSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	if setting.Secret == "" {
+		if setting.Secret, err = generateWebhookSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	setting.WebhookURL = params.WebhookURL
+	setting.BalanceThresholds = joinInt64s(params.BalanceThresholds)
+	setting.ExpiryDays = joinInts(params.ExpiryDays)
+	setting.Email = params.Email
+	setting.Language = params.Language
+	setting.Enabled = params.Enabled
+	setting.UpdatedAt = time.Now()
+	if setting.ID == 0 {
+		setting.CreatedAt = time.Now()
+	}
+
+	if err := s.db.Save(setting).Error; err != nil {
+		return nil, err
+	}
+	return setting, nil
+}
+
+// RotateSecret 重新生成签名密钥
+func (s *NotificationService) RotateSecret(userID uint) (*model.NotificationSetting, error) {
+	setting, err := s.GetSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	if setting.ID == 0 {
+		return nil, fmt.Errorf("通知设置不存在")
+	}
+
+	if setting.Secret, err = generateWebhookSecret(); err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(setting).Updates(map[string]interface{}{
+		"secret":     setting.Secret,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+	return setting, nil
+}
+
+// CheckUser 检查用户余额和到期时间，越过阈值时发出通知
+// 同一阈值只通知一次，余额回升到阈值以上或到期时间变化后才会再次通知
+func (s *NotificationService) CheckUser(user *model.User) {
+	setting, err := s.GetSettings(user.ID)
+	if err != nil {
+		logger.Errorf("NotificationService get settings failed: %v", err)
+		return
+	}
+	s.check(setting, user)
+}
+
+func (s *NotificationService) check(setting *model.NotificationSetting, user *model.User) {
+	if !setting.Enabled || (setting.WebhookURL == "" && setting.Email == "") {
+		return
+	}
+
+	s.checkBalance(setting, user)
+	s.checkExpiry(setting, user)
+}
+
+// StartScanLoop 定时检查所有开启通知的用户，未调用接口的用户同样能按时收到到期和余额提醒
+func (s *NotificationService) StartScanLoop() {
+	interval := s.config.Notification.ScanIntervalMinutes
+	if interval <= 0 {
+		interval = 10
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.scan()
+		}
+	}()
+}
+
+// scan 分批检查开启通知的用户，多实例同时扫描时由去重键保证同一阈值只通知一次
+func (s *NotificationService) scan() {
+	const batchSize = 200
+
+	var lastID uint
+	for {
+		var settings []model.NotificationSetting
+		if err := s.db.Where("id > ? AND enabled = ?", lastID, true).
+			Order("id ASC").
+			Limit(batchSize).
+			Find(&settings).Error; err != nil {
+			logger.Errorf("NotificationService scan settings failed: %v", err)
+			return
+		}
+
+		for i := range settings {
+			var user model.User
+			if err := s.db.First(&user, settings[i].UserID).Error; err != nil {
+				if err != gorm.ErrRecordNotFound {
+					logger.Errorf("NotificationService scan load user failed: %v", err)
+				}
+				continue
+			}
+			s.check(&settings[i], &user)
+		}
+
+		if len(settings) < batchSize {
+			return
+		}
+		lastID = settings[len(settings)-1].ID
+	}
+}
+
+// checkBalance 余额一次越过多个阈值时只通知最低的一个
+func (s *NotificationService) checkBalance(setting *model.NotificationSetting, user *model.User) {
+	thresholds := parseInt64s(setting.BalanceThresholds)
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i] < thresholds[j] })
+
+	notified := false
+	for _, threshold := range thresholds {
+		key := fmt.Sprintf("notify:balance:%d:%d", user.ID, threshold)
+		if user.RemainQuota >= threshold {
+			s.cache.Delete(key)
+			continue
+		}
+
+		ok, err := s.cache.SetNX(key, "1", notifyDedupTTL)
+		if err != nil || !ok || notified {
+			notified = true
+			continue
+		}
+		notified = true
+
+		s.emit(setting, EventBalanceLow, map[string]interface{}{
+			"threshold": money.FromQuota(threshold),
+			"balance":   money.FromQuota(user.RemainQuota),
+		})
+		s.sendEmail(setting, notifier.EventBalanceLow, map[string]interface{}{
+			"Threshold": formatMoney(threshold),
+			"Balance":   formatMoney(user.RemainQuota),
+		})
+	}
+}
+
+// checkExpiry 同时满足多个提醒天数时只通知最近的一个
+func (s *NotificationService) checkExpiry(setting *model.NotificationSetting, user *model.User) {
+	remaining := user.ExpiredTime - time.Now().Unix()
+	if user.ExpiredTime <= 0 || remaining <= 0 {
+		return
+	}
+
+	days := parseInts(setting.ExpiryDays)
+	sort.Ints(days)
+
+	notified := false
+	for _, d := range days {
+		if remaining > int64(d)*86400 {
+			continue
+		}
+
+		// 到期时间纳入去重键，延长有效期后重新提醒
+		key := fmt.Sprintf("notify:expiry:%d:%d:%d", user.ID, user.ExpiredTime, d)
+		ok, err := s.cache.SetNX(key, "1", int(remaining)+86400)
+		if err != nil || !ok || notified {
+			notified = true
+			continue
+		}
+		notified = true
+
+		s.emit(setting, EventAccountExpiring, map[string]interface{}{
+			"expired_time": user.ExpiredTime,
+			"days":         d,
+			"days_left":    remaining / 86400,
+		})
+		s.sendEmail(setting, notifier.EventAccountExpiring, map[string]interface{}{
+			"ExpiredAt": time.Unix(user.ExpiredTime, 0).Format("2006-01-02 15:04:05"),
+			"DaysLeft":  remaining / 86400,
+		})
+	}
+}
+
+// SendTest 发送测试事件
+func (s *NotificationService) SendTest(userID uint) error {
+	setting, err := s.GetSettings(userID)
+	if err != nil {
+		return err
+	}
+	if setting.WebhookURL == "" {
+		return fmt.Errorf("webhook_url is not configured")
+	}
+	return s.emit(setting, EventTest, map[string]interface{}{"message": "test event"})
+}
+
+// ListDeliveries 分页获取用户的投递记录
+func (s *NotificationService) ListDeliveries(userID uint, page, pageSize int) ([]model.WebhookDelivery, int64, error) {
+	var deliveries []model.WebhookDelivery
+	var total int64
+
+	if err := s.db.Model(&model.WebhookDelivery{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := s.db.Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(pageSize).
+		Offset(offset).
+		Find(&deliveries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
+
+// sendEmail 异步发送邮件通知，未设置邮箱或事件未启用时忽略
+func (s *NotificationService) sendEmail(setting *model.NotificationSetting, event string, data map[string]interface{}) {
+	if setting.Email == "" || !s.notifier.Enabled(event) {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := s.notifier.Notify(ctx, event, setting.Language, []string{setting.Email}, data); err != nil {
+			logger.Errorf("NotificationService send email to user %d failed: %v", setting.UserID, err)
+		}
+	}()
+}
+
+// emit 记录投递并加入投递队列，未设置 webhook 时忽略
+func (s *NotificationService) emit(setting *model.NotificationSetting, eventType string, data interface{}) error {
+	if setting.WebhookURL == "" {
+		return nil
+	}
+
+	eventID, err := generateEventID()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	payload, err := json.Marshal(WebhookEvent{
+		ID:        eventID,
+		Type:      eventType,
+		CreatedAt: now.Unix(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	delivery := model.WebhookDelivery{
+		UserID:      setting.UserID,
+		EventID:     eventID,
+		Event:       eventType,
+		URL:         setting.WebhookURL,
+		Payload:     string(payload),
+		Status:      DeliveryStatusPending,
+		NextRetryAt: now.Unix() + deliveryLeaseSeconds, // 队列消息丢失时由重试任务补投
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.db.Create(&delivery).Error; err != nil {
+		logger.Errorf("NotificationService create delivery failed: %v", err)
+		return err
+	}
+
+	return s.queue.Push(WebhookQueue, map[string]interface{}{"delivery_id": delivery.ID})
+}
+
+// ProcessDelivery 处理投递队列中的消息，失败时按退避间隔安排重试
+func (s *NotificationService) ProcessDelivery(data []byte) error {
+	var msg struct {
+		DeliveryID uint `json:"delivery_id"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+
+	var delivery model.WebhookDelivery
+	if err := s.db.First(&delivery, msg.DeliveryID).Error; err != nil {
+		return err
+	}
+	if delivery.Status != DeliveryStatusPending {
+		return nil
+	}
+
+	setting, err := s.GetSettings(delivery.UserID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	code, sendErr := s.client.Send(ctx, delivery.URL, setting.Secret, delivery.EventID, delivery.Event, []byte(delivery.Payload))
+
+	attempts := delivery.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":      attempts,
+		"response_code": code,
+		"updated_at":    time.Now(),
+	}
+	switch {
+	case sendErr == nil:
+		updates["status"] = DeliveryStatusSuccess
+		updates["error"] = ""
+	case attempts >= s.maxAttempts():
+		updates["status"] = DeliveryStatusFailed
+		updates["error"] = truncate(sendErr.Error(), 512)
+	default:
+		backoff := webhookRetryBackoff[min(attempts, len(webhookRetryBackoff))-1]
+		updates["next_retry_at"] = time.Now().Unix() + backoff
+		updates["error"] = truncate(sendErr.Error(), 512)
+	}
+
+	return s.db.Model(&delivery).Updates(updates).Error
+}
+
+// StartRetryLoop 定时将到期的重试投递重新入队
+func (s *NotificationService) StartRetryLoop() {
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.requeueDue()
+		}
+	}()
+}
+
+func (s *NotificationService) requeueDue() {
+	now := time.Now().Unix()
+
+	var deliveries []model.WebhookDelivery
+	if err := s.db.Select("id", "next_retry_at").
+		Where("status = ? AND next_retry_at <= ?", DeliveryStatusPending, now).
+		Order("id ASC").
+		Limit(100).
+		Find(&deliveries).Error; err != nil {
+		logger.Errorf("NotificationService query due deliveries failed: %v", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		// 条件更新抢占租约，多实例下同一投递只会被一个实例重新入队
+		result := s.db.Model(&model.WebhookDelivery{}).
+			Where("id = ? AND next_retry_at = ?", delivery.ID, delivery.NextRetryAt).
+			Update("next_retry_at", now+deliveryLeaseSeconds)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+		s.queue.Push(WebhookQueue, map[string]interface{}{"delivery_id": delivery.ID})
+	}
+}
+
+func (s *NotificationService) maxAttempts() int {
+	if s.config.Notification.MaxAttempts > 0 {
+		return s.config.Notification.MaxAttempts
+	}
+	return 6
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func generateEventID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "evt_" + hex.EncodeToString(b), nil
+}
+
+func parseInt64s(s string) []int64 {
+	var result []int64
+	for _, v := range splitList(s) {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+func parseInts(s string) []int {
+	var result []int
+	for _, v := range splitList(s) {
+		if n, err := strconv.Atoi(v); err == nil {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+func joinInt64s(values []int64) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, strconv.FormatInt(v, 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, strconv.Itoa(v))
+	}
+	return strings.Join(parts, ",")
+}
+
+// formatMoney 额度格式化为带货币符号的展示金额
+func formatMoney(quota int64) string {
+	return fmt.Sprintf("%s%.2f", money.Symbol(), money.QuotaToDisplay(quota))
+}
+
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen]
+}
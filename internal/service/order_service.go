@@ -0,0 +1,236 @@
+// internal/service/order_service.go
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"llmapisrv/config"
+	"llmapisrv/internal/model"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/money"
+	"llmapisrv/pkg/payment"
+)
+
+// 订单状态
+const (
+	OrderStatusPending = 0
+	OrderStatusPaid    = 1
+	OrderStatusClosed  = 2
+)
+
+// errOrderAlreadyPaid 订单已到账，重复通知直接应答成功
+var errOrderAlreadyPaid = errors.New("order already paid")
+
+type OrderService struct {
+	db          *gorm.DB
+	userService *UserService
+	config      *config.Config
+	providers   map[string]payment.PaymentProvider
+}
+
+func NewOrderService(db *gorm.DB, userService *UserService, config *config.Config, providers ...payment.PaymentProvider) *OrderService {
+	s := &OrderService{
+		db:          db,
+		userService: userService,
+		config:      config,
+		providers:   make(map[string]payment.PaymentProvider),
+	}
+	for _, p := range providers {
+		s.providers[p.Name()] = p
+	}
+	return s
+}
+
+// Provider 获取已启用的支付渠道
+func (s *OrderService) Provider(name string) (payment.PaymentProvider, bool) {
+	p, ok := s.providers[name]
+	return p, ok
+}
+
+// CreateOrder 创建充值订单并向支付渠道下单
+func (s *OrderService) CreateOrder(ctx context.Context, userID uint, amount int64, providerName string) (*model.Order, *payment.PaymentResult, error) {
+	cfg := s.config.Payment
+	if providerName == "" {
+		providerName = cfg.Provider
+	}
+	provider, ok := s.Provider(providerName)
+	if !ok {
+		return nil, nil, fmt.Errorf("payment provider not available: %s", providerName)
+	}
+
+	if amount <= 0 || (cfg.MinAmount > 0 && amount < cfg.MinAmount) || (cfg.MaxAmount > 0 && amount > cfg.MaxAmount) {
+		return nil, nil, fmt.Errorf("充值金额超出范围")
+	}
+
+	quota, err := s.amountToQuota(amount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	orderNo, err := generateOrderNo()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expireMinutes := cfg.OrderExpireMinutes
+	if expireMinutes <= 0 {
+		expireMinutes = 30
+	}
+	now := time.Now()
+	expireTime := now.Add(time.Duration(expireMinutes) * time.Minute)
+
+	order := model.Order{
+		OrderNo:     orderNo,
+		UserID:      userID,
+		Provider:    providerName,
+		Amount:      amount,
+		Quota:       quota,
+		Status:      OrderStatusPending,
+		ExpiredTime: expireTime.Unix(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.db.Create(&order).Error; err != nil {
+		return nil, nil, err
+	}
+
+	result, err := provider.CreatePayment(ctx, &payment.PaymentRequest{
+		OrderNo:    orderNo,
+		Amount:     amount,
+		Subject:    fmt.Sprintf("账户充值 %.2f 元", float64(amount)/100),
+		NotifyURL:  s.notifyURL(providerName),
+		ExpireTime: expireTime,
+	})
+	if err != nil {
+		s.db.Model(&order).Update("status", OrderStatusClosed)
+		return nil, nil, fmt.Errorf("create payment failed: %w", err)
+	}
+
+	return &order, result, nil
+}
+
+// HandleNotify 处理支付渠道的异步通知，同一订单重复通知只到账一次
+func (s *OrderService) HandleNotify(provider payment.PaymentProvider, r *http.Request) error {
+	notification, err := provider.ParseNotify(r)
+	if err != nil {
+		return err
+	}
+	if !notification.Paid {
+		return nil
+	}
+
+	var order model.Order
+	if err := s.db.Where("order_no = ? AND provider = ?", notification.OrderNo, provider.Name()).
+		First(&order).Error; err != nil {
+		return err
+	}
+
+	err = s.userService.CreditQuota(order.UserID, func(tx *gorm.DB) (*Credit, error) {
+		// 锁定订单行，并发的重复通知在此串行
+		var locked model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, order.ID).Error; err != nil {
+			return nil, err
+		}
+		if locked.Status == OrderStatusPaid {
+			return nil, errOrderAlreadyPaid
+		}
+		if locked.Amount != notification.Amount {
+			return nil, fmt.Errorf("amount mismatch: order %d, paid %d", locked.Amount, notification.Amount)
+		}
+
+		// 已关闭的订单仍按实际支付到账
+		result := tx.Model(&model.Order{}).
+			Where("id = ? AND status <> ?", locked.ID, OrderStatusPaid).
+			Updates(map[string]interface{}{
+				"status":     OrderStatusPaid,
+				"trade_no":   notification.TradeNo,
+				"paid_time":  time.Now().Unix(),
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, errOrderAlreadyPaid
+		}
+
+		return &Credit{Quota: locked.Quota, Source: "order:" + locked.OrderNo}, nil
+	})
+	if errors.Is(err, errOrderAlreadyPaid) {
+		return nil
+	}
+	if err != nil {
+		logger.Errorf("OrderService credit order %s failed: %v", order.OrderNo, err)
+		return err
+	}
+
+	logger.Infof("Order %s paid, user %d credited %d quota", order.OrderNo, order.UserID, order.Quota)
+	return nil
+}
+
+// GetOrder 获取用户的订单
+func (s *OrderService) GetOrder(userID uint, orderNo string) (*model.Order, error) {
+	var order model.Order
+	if err := s.db.Where("order_no = ? AND user_id = ?", orderNo, userID).First(&order).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("订单不存在")
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// ListOrders 分页获取用户的订单
+func (s *OrderService) ListOrders(userID uint, page, pageSize int) ([]model.Order, int64, error) {
+	var orders []model.Order
+	var total int64
+
+	if err := s.db.Model(&model.Order{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := s.db.Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(pageSize).
+		Offset(offset).
+		Find(&orders).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return orders, total, nil
+}
+
+// amountToQuota 金额（分）换算为额度
+// 未配置每元额度时只有展示货币为人民币才能按展示货币换算，否则拒绝下单，避免把人民币按美元入账
+func (s *OrderService) amountToQuota(amount int64) (int64, error) {
+	if quotaPerYuan := s.config.Payment.QuotaPerYuan; quotaPerYuan > 0 {
+		return amount * quotaPerYuan / 100, nil
+	}
+	if money.Currency() != "CNY" {
+		return 0, fmt.Errorf("payment.quota_per_yuan is not configured and display currency is %s, cannot convert CNY to quota", money.Currency())
+	}
+	return money.DisplayToQuota(float64(amount) / 100), nil
+}
+
+func (s *OrderService) notifyURL(providerName string) string {
+	return s.config.Payment.NotifyBaseURL + "/api/payment/notify/" + providerName
+}
+
+// generateOrderNo 生成订单号：时间 + 随机数
+func generateOrderNo() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "P" + time.Now().Format("20060102150405") + hex.EncodeToString(b), nil
+}
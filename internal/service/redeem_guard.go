@@ -0,0 +1,206 @@
+// internal/service/redeem_guard.go
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"llmapisrv/config"
+	"llmapisrv/pkg/cache"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/notifier"
+)
+
+var (
+	// 兑换失败计数器
+	redemptionFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "redemption_failures_total",
+			Help: "Total number of failed redemption attempts",
+		},
+		[]string{"endpoint", "reason"},
+	)
+
+	// 兑换锁定计数器
+	redemptionLockoutsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "redemption_lockouts_total",
+			Help: "Total number of redemption lockouts",
+		},
+		[]string{"subject"},
+	)
+
+	// 疑似爆破告警计数器
+	redemptionBruteforceAlertsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "redemption_bruteforce_alerts_total",
+			Help: "Total number of suspected redemption code brute-force alerts",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(redemptionFailuresTotal)
+	prometheus.MustRegister(redemptionLockoutsTotal)
+	prometheus.MustRegister(redemptionBruteforceAlertsTotal)
+}
+
+// 兑换防爆破的接口，各接口单独计数和锁定
+const (
+	RedeemEndpointRedeem = "redeem"      // 兑换
+	RedeemEndpointInfo   = "redeem_info" // 兑换前查询
+)
+
+// RedeemCodeInvalidMessage 兑换失败时对外的统一提示
+const RedeemCodeInvalidMessage = "兑换码无效或不可用"
+
+// RedeemCodeError 兑换码不可用，对外统一提示，不区分具体原因，避免泄露兑换码状态
+type RedeemCodeError struct {
+	Reason string // not_found, exhausted, user_limit, new_user_only
+}
+
+func (e *RedeemCodeError) Error() string {
+	return RedeemCodeInvalidMessage
+}
+
+// RedeemGuardService 兑换防爆破，按用户和IP分别统计失败次数并渐进锁定
+type RedeemGuardService struct {
+	cache    *cache.RedisCache
+	config   *config.Config
+	notifier *notifier.Notifier
+}
+
+func NewRedeemGuardService(cache *cache.RedisCache, config *config.Config, notifier *notifier.Notifier) *RedeemGuardService {
+	return &RedeemGuardService{
+		cache:    cache,
+		config:   config,
+		notifier: notifier,
+	}
+}
+
+// IsLocked 判断用户或IP在该接口是否处于锁定状态
+func (s *RedeemGuardService) IsLocked(endpoint string, userID uint, ip string) bool {
+	for _, subject := range guardSubjects(userID, ip) {
+		if locked, err := s.cache.Exists(endpoint + ":lock:" + subject); err == nil && locked {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordFailure 记录一次失败，达到该接口的阈值后锁定，锁定时长随锁定次数翻倍
+func (s *RedeemGuardService) RecordFailure(endpoint string, userID uint, ip, reason string) {
+	redemptionFailuresTotal.WithLabelValues(endpoint, reason).Inc()
+
+	maxFailures, window, lockout, maxLockout, alertThreshold := s.settings(endpoint)
+
+	for _, subject := range guardSubjects(userID, ip) {
+		count, err := s.cache.Incr(endpoint+":fail:"+subject, window)
+		if err != nil {
+			logger.Errorf("RedeemGuardService incr failure count failed: %v", err)
+			continue
+		}
+		if count < int64(maxFailures) {
+			continue
+		}
+
+		// 24小时内的锁定次数决定本次锁定时长
+		level, _ := s.cache.Incr(endpoint+":lock_level:"+subject, 86400)
+		duration := lockout
+		for i := int64(1); i < level && duration < maxLockout; i++ {
+			duration *= 2
+		}
+		duration = min(duration, maxLockout)
+
+		s.cache.Set(endpoint+":lock:"+subject, strconv.FormatInt(level, 10), duration)
+		s.cache.Delete(endpoint + ":fail:" + subject)
+		redemptionLockoutsTotal.WithLabelValues(subjectType(userID, subject)).Inc()
+		logger.Warn("Redemption attempts locked",
+			zap.String("endpoint", endpoint),
+			zap.String("subject", subject),
+			zap.Int64("level", level),
+			zap.Int("lockout_seconds", duration),
+		)
+	}
+
+	// 单个key一小时内大量失败，疑似爆破，两个接口合并计数
+	alertCount, err := s.cache.Incr(fmt.Sprintf("redeem:alert:user:%d", userID), 3600)
+	if err == nil && alertCount == int64(alertThreshold) {
+		redemptionBruteforceAlertsTotal.Inc()
+		logger.Warn("Suspected redemption code brute-force",
+			zap.Uint("user_id", userID),
+			zap.String("ip", ip),
+			zap.Int64("failures_last_hour", alertCount),
+		)
+		go s.notifyAdmins(userID, ip, alertCount)
+	}
+}
+
+// notifyAdmins 邮件告警管理员
+func (s *RedeemGuardService) notifyAdmins(userID uint, ip string, failures int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := s.notifier.NotifyAdmins(ctx, notifier.EventRedeemBruteforce, map[string]interface{}{
+		"UserID":   userID,
+		"IP":       ip,
+		"Failures": failures,
+		"Time":     time.Now().Format("2006-01-02 15:04:05"),
+	}); err != nil {
+		logger.Errorf("RedeemGuardService notify admins failed: %v", err)
+	}
+}
+
+// Reset 成功后清除用户在该接口的失败计数
+func (s *RedeemGuardService) Reset(endpoint string, userID uint) {
+	s.cache.Delete(fmt.Sprintf("%s:fail:user:%d", endpoint, userID))
+}
+
+func (s *RedeemGuardService) settings(endpoint string) (maxFailures, window, lockout, maxLockout, alertThreshold int) {
+	cfg := s.config.Redemption
+	maxFailures, window, lockout, maxLockout, alertThreshold =
+		cfg.MaxFailures, cfg.FailureWindow, cfg.LockoutSeconds, cfg.MaxLockoutSeconds, cfg.AlertThreshold
+
+	// 查询接口不消耗兑换码，失败次数上限更低
+	if endpoint == RedeemEndpointInfo {
+		maxFailures = cfg.InfoMaxFailures
+		if maxFailures <= 0 {
+			maxFailures = 3
+		}
+	}
+	if maxFailures <= 0 {
+		maxFailures = 5
+	}
+	if window <= 0 {
+		window = 600
+	}
+	if lockout <= 0 {
+		lockout = 300
+	}
+	if maxLockout <= 0 {
+		maxLockout = 86400
+	}
+	if alertThreshold <= 0 {
+		alertThreshold = 20
+	}
+	return
+}
+
+func guardSubjects(userID uint, ip string) []string {
+	subjects := []string{fmt.Sprintf("user:%d", userID)}
+	if ip != "" {
+		subjects = append(subjects, "ip:"+ip)
+	}
+	return subjects
+}
+
+func subjectType(userID uint, subject string) string {
+	if subject == fmt.Sprintf("user:%d", userID) {
+		return "user"
+	}
+	return "ip"
+}
@@ -4,125 +4,575 @@ package service
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"llmapisrv/config"
 	"llmapisrv/internal/model"
 )
 
+// 兑换码类型
+const (
+	RedeemTypeQuota        = "quota"        // 增加额度
+	RedeemTypeSubscription = "subscription" // 延长账户有效期
+	RedeemTypeTier         = "tier"         // 限时升级账户等级
+	RedeemTypeModel        = "model"        // 解锁显示模型
+)
+
+// ErrBatchNumExists 指定的批次号已被使用
+var ErrBatchNumExists = errors.New("批次号已存在")
+
 type RedemptionService struct {
-	db *gorm.DB
+	db          *gorm.DB
+	userService *UserService
+	config      *config.Config
 }
 
-func NewRedemptionService(db *gorm.DB) *RedemptionService {
+func NewRedemptionService(db *gorm.DB, userService *UserService, config *config.Config) *RedemptionService {
 	return &RedemptionService{
-		db: db,
+		db:          db,
+		userService: userService,
+		config:      config,
 	}
 }
 
-// GenerateCodes 生成兑换码
-func (s *RedemptionService) GenerateCodes(count int, quota int64, batchNum string) ([]string, error) {
-	codes := make([]string, 0, count)
+// RedeemCodeDetail 兑换码内容，兑换前查询和兑换后返回
+type RedeemCodeDetail struct {
+	Type   string                 `json:"type"`
+	Quota  int64                  `json:"quota"`
+	Effect model.RedemptionEffect `json:"effect"`
+}
+
+// GenerateCodesParams 生成兑换码参数
+type GenerateCodesParams struct {
+	Name         string
+	BatchNum     string
+	Code         string // 自定义兑换码，仅生成单个活动码时使用
+	Count        int
+	Type         string // 兑换码类型，默认为 quota
+	Quota        int64
+	Effect       model.RedemptionEffect
+	MaxUses      int // 每个兑换码可兑换总次数，0为不限制
+	PerUserLimit int // 每个用户可兑换次数，0为不限制
+	NewUserOnly  bool
+	StartTime    int64
+	EndTime      int64
+	CreatedBy    string
+}
+
+// BatchProgress 批次兑换进度
+type BatchProgress struct {
+	model.RedemptionBatch
+	UsedCount     int64 `json:"used_count"`     // 已用完的兑换码数
+	RevokedCount  int64 `json:"revoked_count"`  // 已吊销的兑换码数
+	UnusedCount   int64 `json:"unused_count"`   // 仍可兑换的兑换码数
+	RedeemedTimes int64 `json:"redeemed_times"` // 累计兑换次数
+}
+
+// GenerateCodes 生成兑换码，批次和兑换码在同一事务中写入
+func (s *RedemptionService) GenerateCodes(params GenerateCodesParams) (*model.RedemptionBatch, []string, error) {
+	codes := make([]string, 0, params.Count)
 
-	// 生成批次号
+	// 生成批次号，带随机后缀避免同一秒内生成的批次冲突
+	batchNum := params.BatchNum
 	if batchNum == "" {
-		batchNum = fmt.Sprintf("B%d", time.Now().Unix())
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			return nil, nil, err
+		}
+		batchNum = fmt.Sprintf("B%d%s", time.Now().Unix(), hex.EncodeToString(suffix))
 	}
 
-	// 批量生成兑换码
-	for i := 0; i < count; i++ {
-		// 生成随机字符串
-		b := make([]byte, 12) // 16字节 -> 24字符的base64
-		if _, err := rand.Read(b); err != nil {
-			return nil, err
-		}
+	if params.Code != "" && params.Count != 1 {
+		return nil, nil, fmt.Errorf("自定义兑换码只能生成一个")
+	}
 
-		code := fmt.Sprintf("%s-%s", batchNum, base64.URLEncoding.EncodeToString(b)[:16])
-		code = strings.ReplaceAll(code, "-", "") // 移除可能的连字符
-		code = strings.ReplaceAll(code, "_", "") // 移除下划线
+	if params.Type == "" {
+		params.Type = RedeemTypeQuota
+	}
+	if err := s.validateEffect(params.Type, params.Quota, params.Effect); err != nil {
+		return nil, nil, err
+	}
 
-		// 添加连字符使其更易读
-		formattedCode := fmt.Sprintf("RC-%s-%s-%s",
-			code[:4], code[4:8], code[8:])
+	batch := model.RedemptionBatch{
+		BatchNum:     batchNum,
+		Name:         params.Name,
+		CreatedBy:    params.CreatedBy,
+		Type:         params.Type,
+		Quota:        params.Quota,
+		Effect:       params.Effect,
+		Count:        params.Count,
+		MaxUses:      params.MaxUses,
+		PerUserLimit: params.PerUserLimit,
+		NewUserOnly:  params.NewUserOnly,
+		StartTime:    params.StartTime,
+		EndTime:      params.EndTime,
+		Status:       1,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
 
-		// 保存到数据库
-		redemptionCode := model.RedemptionCode{
-			Code:      formattedCode,
-			Quota:     quota,
-			Used:      false,
-			CreatedAt: time.Now(),
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var exists int64
+		if err := tx.Model(&model.RedemptionBatch{}).Where("batch_num = ?", batchNum).Count(&exists).Error; err != nil {
+			return err
+		}
+		if exists > 0 {
+			return ErrBatchNumExists
 		}
 
-		if err := s.db.Create(&redemptionCode).Error; err != nil {
-			return nil, err
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
+		}
+
+		redemptionCodes := make([]model.RedemptionCode, 0, params.Count)
+
+		// 批量生成兑换码
+		for i := 0; i < params.Count; i++ {
+			// 生成随机字符串
+			b := make([]byte, 12) // 16字节 -> 24字符的base64
+			if _, err := rand.Read(b); err != nil {
+				return err
+			}
+
+			code := fmt.Sprintf("%s-%s", batchNum, base64.URLEncoding.EncodeToString(b)[:16])
+			code = strings.ReplaceAll(code, "-", "") // 移除可能的连字符
+			code = strings.ReplaceAll(code, "_", "") // 移除下划线
+
+			// 添加连字符使其更易读
+			formattedCode := fmt.Sprintf("RC-%s-%s-%s",
+				code[:4], code[4:8], code[8:])
+			if params.Code != "" {
+				formattedCode = params.Code
+			}
+
+			redemptionCodes = append(redemptionCodes, model.RedemptionCode{
+				Code:         formattedCode,
+				BatchID:      batch.ID,
+				Type:         params.Type,
+				Quota:        params.Quota,
+				Effect:       params.Effect,
+				Used:         false,
+				MaxUses:      params.MaxUses,
+				PerUserLimit: params.PerUserLimit,
+				NewUserOnly:  params.NewUserOnly,
+				Status:       1,
+				StartTime:    params.StartTime,
+				ExpiredTime:  params.EndTime,
+				CreatedAt:    time.Now(),
+			})
+			codes = append(codes, formattedCode)
 		}
 
-		codes = append(codes, formattedCode)
+		// 保存到数据库
+		return tx.CreateInBatches(redemptionCodes, 200).Error
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return codes, nil
+	return &batch, codes, nil
 }
 
-// RedeemCode 兑换码兑换
-func (s *RedemptionService) RedeemCode(code string, userID uint) (int64, error) {
-	var redemptionCode model.RedemptionCode
+// ListBatches 分页获取批次及兑换进度
+func (s *RedemptionService) ListBatches(page, pageSize int) ([]BatchProgress, int64, error) {
+	var batches []model.RedemptionBatch
+	var total int64
 
-	// 查找兑换码
-	if err := s.db.Where("code = ? AND used = ?", code, false).First(&redemptionCode).Error; err != nil {
+	if err := s.db.Model(&model.RedemptionBatch{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := s.db.Order("id DESC").
+		Limit(pageSize).
+		Offset(offset).
+		Find(&batches).Error; err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]BatchProgress, 0, len(batches))
+	for _, batch := range batches {
+		progress, err := s.batchProgress(batch)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, *progress)
+	}
+
+	return result, total, nil
+}
+
+// GetBatch 获取批次及兑换进度
+func (s *RedemptionService) GetBatch(id uint) (*BatchProgress, error) {
+	var batch model.RedemptionBatch
+	if err := s.db.First(&batch, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return 0, fmt.Errorf("兑换码无效或已被使用")
+			return nil, fmt.Errorf("批次不存在")
 		}
-		return 0, err
+		return nil, err
 	}
 
-	// 开启事务
-	tx := s.db.Begin()
+	return s.batchProgress(batch)
+}
+
+// RevokeBatch 吊销整个批次，未使用的兑换码全部失效
+func (s *RedemptionService) RevokeBatch(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.RedemptionBatch{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"status":     0,
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("批次不存在")
+		}
+
+		return tx.Model(&model.RedemptionCode{}).
+			Where("batch_id = ? AND used = ?", id, false).
+			Update("status", 0).Error
+	})
+}
+
+// RevokeCode 吊销单个未使用的兑换码
+func (s *RedemptionService) RevokeCode(code string) error {
+	result := s.db.Model(&model.RedemptionCode{}).
+		Where("code = ? AND used = ?", code, false).
+		Update("status", 0)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("兑换码不存在或已被使用")
+	}
+	return nil
+}
 
-	// 标记兑换码为已使用
-	if err := tx.Model(&redemptionCode).Updates(map[string]interface{}{
-		"used":    true,
-		"used_at": time.Now(),
-		"used_by": userID,
-	}).Error; err != nil {
-		tx.Rollback()
-		return 0, err
+// ExportUnusedCodes 逐行导出批次中未使用且未吊销的兑换码
+func (s *RedemptionService) ExportUnusedCodes(batchID uint, w io.Writer) error {
+	rows, err := s.db.Model(&model.RedemptionCode{}).
+		Select("code", "type", "quota", "effect", "start_time", "expired_time").
+		Where("batch_id = ? AND used = ? AND status = ?", batchID, false, 1).
+		Order("id ASC").
+		Rows()
+	if err != nil {
+		return err
 	}
+	defer rows.Close()
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"code", "type", "quota", "effect", "start_time", "expired_time"})
 
-	// 记录兑换日志
-	redemptionLog := model.RedemptionLog{
-		UserID:    userID,
-		Code:      code,
-		Quota:     redemptionCode.Quota,
-		CreatedAt: time.Now(),
+	for rows.Next() {
+		var code model.RedemptionCode
+		if err := s.db.ScanRows(rows, &code); err != nil {
+			return err
+		}
+		effect, _ := code.Effect.Value()
+		writer.Write([]string{
+			code.Code,
+			code.Type,
+			strconv.FormatInt(code.Quota, 10),
+			effect.(string),
+			strconv.FormatInt(code.StartTime, 10),
+			strconv.FormatInt(code.ExpiredTime, 10),
+		})
 	}
 
-	if err := tx.Create(&redemptionLog).Error; err != nil {
-		tx.Rollback()
-		return 0, err
+	writer.Flush()
+	return writer.Error()
+}
+
+func (s *RedemptionService) batchProgress(batch model.RedemptionBatch) (*BatchProgress, error) {
+	progress := &BatchProgress{RedemptionBatch: batch}
+
+	var stats []struct {
+		Used      bool
+		Status    int
+		Total     int64
+		UsedCount int64
+	}
+	if err := s.db.Model(&model.RedemptionCode{}).
+		Select("used, status, COUNT(*) AS total, SUM(used_count) AS used_count").
+		Where("batch_id = ?", batch.ID).
+		Group("used, status").
+		Scan(&stats).Error; err != nil {
+		return nil, err
 	}
 
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
-		return 0, err
+	for _, stat := range stats {
+		progress.RedeemedTimes += stat.UsedCount
+		switch {
+		case stat.Used:
+			progress.UsedCount += stat.Total
+		case stat.Status != 1:
+			progress.RevokedCount += stat.Total
+		default:
+			progress.UnusedCount += stat.Total
+		}
 	}
 
-	return redemptionCode.Quota, nil
+	return progress, nil
+}
+
+// checkRedeemLimits 校验总次数、每用户次数和新用户限制，需在锁定兑换码行后调用
+func (s *RedemptionService) checkRedeemLimits(tx *gorm.DB, redemptionCode *model.RedemptionCode, userID uint) error {
+	if redemptionCode.MaxUses > 0 && redemptionCode.UsedCount >= redemptionCode.MaxUses {
+		return &RedeemCodeError{Reason: "exhausted"}
+	}
+
+	if redemptionCode.PerUserLimit > 0 {
+		var count int64
+		if err := tx.Model(&model.RedemptionLog{}).
+			Where("code = ? AND user_id = ?", redemptionCode.Code, userID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(redemptionCode.PerUserLimit) {
+			return &RedeemCodeError{Reason: "user_limit"}
+		}
+	}
+
+	if redemptionCode.NewUserOnly {
+		var user model.User
+		if err := tx.Select("created_at").First(&user, userID).Error; err != nil {
+			return err
+		}
+		// 活动开始（未设置时为兑换码创建时间）之后创建的账户视为新用户
+		campaignStart := redemptionCode.CreatedAt
+		if redemptionCode.StartTime > 0 {
+			campaignStart = time.Unix(redemptionCode.StartTime, 0)
+		}
+		if user.CreatedAt.Before(campaignStart) {
+			return &RedeemCodeError{Reason: "new_user_only"}
+		}
+	}
+
+	return nil
+}
+
+// availableCodeScope 可兑换的兑换码条件：未使用、未吊销、在有效期内
+func availableCodeScope(tx *gorm.DB, code string, now int64) *gorm.DB {
+	return tx.Where("code = ? AND used = ? AND status = ?", code, false, 1).
+		Where("start_time = 0 OR start_time <= ?", now).
+		Where("expired_time = 0 OR expired_time > ?", now)
 }
 
 // RedeemCode 兑换码兑换
-func (s *RedemptionService) RedeemCodeInfo(code string, userID uint) (int64, error) {
+// 标记兑换码、记录日志、应用兑换效果和增加额度在同一个事务中完成，通过行锁和条件更新保证兑换次数不超限
+func (s *RedemptionService) RedeemCode(code string, userID uint) (*RedeemCodeDetail, error) {
+	var detail *RedeemCodeDetail
+
+	err := s.userService.CreditQuota(userID, func(tx *gorm.DB) (*Credit, error) {
+		now := time.Now()
+
+		// 锁定兑换码行，同一兑换码的并发兑换在此串行，保证次数校验准确
+		var redemptionCode model.RedemptionCode
+		if err := availableCodeScope(tx, code, now.Unix()).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&redemptionCode).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, &RedeemCodeError{Reason: "not_found"}
+			}
+			return nil, err
+		}
+
+		if err := s.checkRedeemLimits(tx, &redemptionCode, userID); err != nil {
+			return nil, err
+		}
+
+		// 条件更新：只有兑换次数未被其他请求改变时才会成功
+		usedCount := redemptionCode.UsedCount + 1
+		result := tx.Model(&model.RedemptionCode{}).
+			Where("id = ? AND used = ? AND used_count = ?", redemptionCode.ID, false, redemptionCode.UsedCount).
+			Updates(map[string]interface{}{
+				"used_count": usedCount,
+				"used":       redemptionCode.MaxUses > 0 && usedCount >= redemptionCode.MaxUses,
+				"used_at":    now,
+				"used_by":    userID,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, &RedeemCodeError{Reason: "exhausted"}
+		}
+
+		// 记录兑换日志
+		redemptionLog := model.RedemptionLog{
+			UserID:    userID,
+			Code:      code,
+			Quota:     redemptionCode.Quota,
+			CreatedAt: now,
+		}
+		if err := tx.Create(&redemptionLog).Error; err != nil {
+			return nil, err
+		}
+
+		credit, err := s.applyEffect(tx, &redemptionCode, userID, now)
+		if err != nil {
+			return nil, err
+		}
+
+		detail = codeDetail(&redemptionCode)
+		return credit, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return detail, nil
+}
+
+// RedeemCodeInfo 兑换码内容查询，兑换前展示额度和兑换效果
+func (s *RedemptionService) RedeemCodeInfo(code string, userID uint) (*RedeemCodeDetail, error) {
 	var redemptionCode model.RedemptionCode
 
 	// 查找兑换码
-	if err := s.db.Where("code = ? AND used = ?", code, false).First(&redemptionCode).Error; err != nil {
+	if err := availableCodeScope(s.db, code, time.Now().Unix()).First(&redemptionCode).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return 0, fmt.Errorf("兑换码无效或已被使用")
+			return nil, &RedeemCodeError{Reason: "not_found"}
+		}
+		return nil, err
+	}
+
+	return codeDetail(&redemptionCode), nil
+}
+
+// applyEffect 按兑换码类型应用兑换效果，额度和有效期由 CreditQuota 同步到 New API
+func (s *RedemptionService) applyEffect(tx *gorm.DB, redemptionCode *model.RedemptionCode, userID uint, now time.Time) (*Credit, error) {
+	credit := &Credit{Quota: redemptionCode.Quota, Source: "redemption_code:" + strconv.FormatUint(uint64(redemptionCode.ID), 10)}
+	effect := redemptionCode.Effect
+
+	switch redemptionCode.Type {
+	case RedeemTypeSubscription:
+		credit.ExtendDays = effect.Days
+
+	case RedeemTypeTier:
+		var user model.User
+		if err := tx.Select("id", "tier", "tier_expired_time").First(&user, userID).Error; err != nil {
+			return nil, err
+		}
+		if user.Tier == effect.Tier && user.TierExpiredTime == 0 {
+			break // 已是长期有效的同等级
+		}
+		// 同等级且未到期时顺延，否则从现在开始计算
+		base := now.Unix()
+		if user.Tier == effect.Tier && user.TierExpiredTime > base {
+			base = user.TierExpiredTime
+		}
+		if err := tx.Model(&model.User{}).
+			Where("id = ?", userID).
+			Updates(map[string]interface{}{
+				"tier":              effect.Tier,
+				"tier_expired_time": base + int64(effect.Days)*86400,
+			}).Error; err != nil {
+			return nil, err
+		}
+
+	case RedeemTypeModel:
+		for _, modelName := range effect.Models {
+			if err := grantModel(tx, userID, modelName, effect.Days, now); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return credit, nil
+}
+
+// grantModel 解锁模型，已解锁的顺延有效期，days 为0表示永久
+func grantModel(tx *gorm.DB, userID uint, modelName string, days int, now time.Time) error {
+	var grant model.UserModelGrant
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND model_name = ?", userID, modelName).
+		First(&grant).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	var expiredTime int64
+	if days > 0 {
+		base := now.Unix()
+		if grant.ExpiredTime > base {
+			base = grant.ExpiredTime
 		}
-		return 0, err
+		expiredTime = base + int64(days)*86400
 	}
 
-	return redemptionCode.Quota, nil
+	if err == gorm.ErrRecordNotFound {
+		return tx.Create(&model.UserModelGrant{
+			UserID:      userID,
+			ModelName:   modelName,
+			ExpiredTime: expiredTime,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}).Error
+	}
+
+	if grant.ExpiredTime == 0 {
+		return nil // 已永久解锁
+	}
+	return tx.Model(&grant).Updates(map[string]interface{}{
+		"expired_time": expiredTime,
+		"updated_at":   now,
+	}).Error
+}
+
+// validateEffect 校验兑换码类型与兑换效果是否匹配
+func (s *RedemptionService) validateEffect(codeType string, quota int64, effect model.RedemptionEffect) error {
+	switch codeType {
+	case RedeemTypeQuota:
+		if quota <= 0 {
+			return fmt.Errorf("额度兑换码的 quota 必须大于0")
+		}
+	case RedeemTypeSubscription:
+		if effect.Days <= 0 {
+			return fmt.Errorf("订阅兑换码的 days 必须大于0")
+		}
+	case RedeemTypeTier:
+		if effect.Tier == "" || effect.Days <= 0 {
+			return fmt.Errorf("等级兑换码需要指定 tier 且 days 大于0")
+		}
+	case RedeemTypeModel:
+		if len(effect.Models) == 0 {
+			return fmt.Errorf("模型兑换码需要指定 models")
+		}
+		for _, modelName := range effect.Models {
+			if _, ok := s.config.ModelMapping[modelName]; !ok {
+				return fmt.Errorf("unknown model: %s", modelName)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid type: %s", codeType)
+	}
+	if quota < 0 || effect.Days < 0 {
+		return fmt.Errorf("quota 和 days 不能为负数")
+	}
+	return nil
+}
+
+func codeDetail(redemptionCode *model.RedemptionCode) *RedeemCodeDetail {
+	codeType := redemptionCode.Type
+	if codeType == "" {
+		codeType = RedeemTypeQuota
+	}
+	return &RedeemCodeDetail{
+		Type:   codeType,
+		Quota:  redemptionCode.Quota,
+		Effect: redemptionCode.Effect,
+	}
 }
@@ -0,0 +1,192 @@
+// internal/service/redemption_service_test.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+
+	"llmapisrv/config"
+	"llmapisrv/internal/model"
+	"llmapisrv/pkg/cache"
+	"llmapisrv/pkg/logger"
+)
+
+// openTestDB 打开临时 SQLite 数据库，事务在开始时即获取写锁，模拟 MySQL 行锁下的串行化
+func openTestDB(t *testing.T, name string) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), name+".db") + "?_pragma=busy_timeout(10000)&_txlock=immediate"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: gormLogger.Discard})
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+	return db
+}
+
+func newTestRedemptionService(t *testing.T) (*RedemptionService, *gorm.DB, *gorm.DB) {
+	t.Helper()
+	gatewayDB := openTestDB(t, "gateway")
+	newAPIDB := openTestDB(t, "newapi")
+
+	if err := gatewayDB.AutoMigrate(&model.User{}, &model.APIKey{}, &model.RedemptionBatch{}, &model.RedemptionCode{}, &model.RedemptionLog{}); err != nil {
+		t.Fatalf("migrate gateway: %v", err)
+	}
+	if err := newAPIDB.Exec(`CREATE TABLE tokens (
+		id INTEGER PRIMARY KEY,
+		remain_quota INTEGER NOT NULL DEFAULT 0,
+		expired_time INTEGER NOT NULL DEFAULT 0,
+		status INTEGER NOT NULL DEFAULT 1
+	)`).Error; err != nil {
+		t.Fatalf("migrate new api: %v", err)
+	}
+
+	mr := miniredis.RunT(t)
+	redisCache := cache.NewRedisCache(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	authCache := NewAuthCacheService(gatewayDB, redisCache)
+	userService := NewUserService(gatewayDB, newAPIDB, redisCache, nil, authCache)
+	return NewRedemptionService(gatewayDB, userService, &config.Config{}), gatewayDB, newAPIDB
+}
+
+func TestRedeemCodeSingleUseConcurrent(t *testing.T) {
+	const (
+		workers = 20
+		quota   = 500000
+	)
+	svc, gatewayDB, newAPIDB := newTestRedemptionService(t)
+
+	// 每个并发请求使用不同用户，排除每用户次数限制的影响
+	for i := 1; i <= workers; i++ {
+		user := model.User{ID: uint(i), APIKey: fmt.Sprintf("key-%d", i), TokenID: uint(i), Status: 1, CreatedAt: time.Now()}
+		if err := gatewayDB.Create(&user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		if err := newAPIDB.Exec("INSERT INTO tokens (id) VALUES (?)", i).Error; err != nil {
+			t.Fatalf("create token: %v", err)
+		}
+	}
+
+	_, codes, err := svc.GenerateCodes(GenerateCodesParams{Count: 1, Quota: quota, MaxUses: 1})
+	if err != nil {
+		t.Fatalf("generate code: %v", err)
+	}
+	code := codes[0]
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, errs[i] = svc.RedeemCode(code, uint(i+1))
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	var succeeded, rejected int
+	for _, err := range errs {
+		var codeErr *RedeemCodeError
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.As(err, &codeErr):
+			rejected++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 || rejected != workers-1 {
+		t.Fatalf("succeeded = %d, rejected = %d, want 1 and %d", succeeded, rejected, workers-1)
+	}
+
+	var redemptionCode model.RedemptionCode
+	if err := gatewayDB.Where("code = ?", code).First(&redemptionCode).Error; err != nil {
+		t.Fatalf("load code: %v", err)
+	}
+	if !redemptionCode.Used || redemptionCode.UsedCount != 1 {
+		t.Errorf("code used = %v, used_count = %d, want true and 1", redemptionCode.Used, redemptionCode.UsedCount)
+	}
+
+	var logs int64
+	gatewayDB.Model(&model.RedemptionLog{}).Where("code = ?", code).Count(&logs)
+	if logs != 1 {
+		t.Errorf("redemption logs = %d, want 1", logs)
+	}
+
+	// 额度只增加一次，网关和 New API 两侧一致
+	var localQuota, remoteQuota int64
+	gatewayDB.Model(&model.User{}).Select("COALESCE(SUM(remain_quota), 0)").Scan(&localQuota)
+	newAPIDB.Raw("SELECT COALESCE(SUM(remain_quota), 0) FROM tokens").Scan(&remoteQuota)
+	if localQuota != quota || remoteQuota != quota {
+		t.Errorf("credited quota = %d (gateway) / %d (new api), want %d", localQuota, remoteQuota, quota)
+	}
+}
+
+func TestGenerateCodesBatchNum(t *testing.T) {
+	svc, _, _ := newTestRedemptionService(t)
+
+	// 同一秒内生成的默认批次号不冲突
+	first, _, err := svc.GenerateCodes(GenerateCodesParams{Count: 1, Quota: 1000, MaxUses: 1})
+	if err != nil {
+		t.Fatalf("generate first batch: %v", err)
+	}
+	second, _, err := svc.GenerateCodes(GenerateCodesParams{Count: 1, Quota: 1000, MaxUses: 1})
+	if err != nil {
+		t.Fatalf("generate second batch: %v", err)
+	}
+	if first.BatchNum == second.BatchNum {
+		t.Fatalf("default batch_num collided: %s", first.BatchNum)
+	}
+
+	// 重复使用指定的批次号返回 ErrBatchNumExists
+	_, _, err = svc.GenerateCodes(GenerateCodesParams{BatchNum: first.BatchNum, Count: 1, Quota: 1000, MaxUses: 1})
+	if !errors.Is(err, ErrBatchNumExists) {
+		t.Fatalf("reused batch_num error = %v, want ErrBatchNumExists", err)
+	}
+}
+
+func TestRedeemCodeNewAPIFailureNoDoubleCredit(t *testing.T) {
+	// 失败路径会写错误日志
+	logger.Setup(config.Logger{Level: "error", Filename: filepath.Join(t.TempDir(), "test.log")})
+	svc, gatewayDB, newAPIDB := newTestRedemptionService(t)
+
+	user := model.User{ID: 1, APIKey: "key-1", TokenID: 1, Status: 1, CreatedAt: time.Now()}
+	if err := gatewayDB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	_, codes, err := svc.GenerateCodes(GenerateCodesParams{Count: 1, Quota: 1000, MaxUses: 1})
+	if err != nil {
+		t.Fatalf("generate code: %v", err)
+	}
+
+	// New API 更新失败：网关侧已提交，兑换码已使用，本地额度回退
+	if err := newAPIDB.Exec("DROP TABLE tokens").Error; err != nil {
+		t.Fatalf("drop tokens: %v", err)
+	}
+	if _, err := svc.RedeemCode(codes[0], user.ID); err == nil {
+		t.Fatal("redeem succeeded while new api is unavailable")
+	}
+
+	var remainQuota int64
+	gatewayDB.Model(&model.User{}).Where("id = ?", user.ID).Select("remain_quota").Scan(&remainQuota)
+	if remainQuota != 0 {
+		t.Errorf("local remain_quota = %d after failed new api credit, want 0", remainQuota)
+	}
+
+	// 重试时兑换码已使用，不会再次加额度
+	var codeErr *RedeemCodeError
+	if _, err := svc.RedeemCode(codes[0], user.ID); !errors.As(err, &codeErr) {
+		t.Fatalf("retry error = %v, want RedeemCodeError", err)
+	}
+}
@@ -0,0 +1,217 @@
+// internal/service/request_record_service.go
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"llmapisrv/internal/model"
+	"llmapisrv/pkg/logger"
+)
+
+// 网关调用记录状态
+const (
+	RequestStatusSuccess       = "success"
+	RequestStatusUpstreamError = "upstream_error" // 上游返回错误状态码
+	RequestStatusFailed        = "failed"         // 网关转发失败，如模型不支持、上游连接失败
+	RequestStatusRejected      = "rejected"       // 转发前被拒绝，如超出消费上限
+	RequestStatusCanceled      = "canceled"       // 客户端在流式响应结束前断开
+)
+
+// RequestRecordFilter 调用记录查询条件，零值表示不过滤
+type RequestRecordFilter struct {
+	UserID    uint
+	APIKeyID  *uint
+	Status    string
+	Model     string
+	TraceID   string
+	StartTime int64 // 开始时间戳（含）
+	EndTime   int64 // 结束时间戳（不含）
+}
+
+// ParseRequestRecordFilter 解析查询参数中的过滤条件
+func ParseRequestRecordFilter(query url.Values) (RequestRecordFilter, error) {
+	var filter RequestRecordFilter
+	var err error
+
+	if v := query.Get("start_time"); v != "" {
+		if filter.StartTime, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return filter, fmt.Errorf("invalid start_time")
+		}
+	}
+	if v := query.Get("end_time"); v != "" {
+		if filter.EndTime, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return filter, fmt.Errorf("invalid end_time")
+		}
+	}
+	if filter.StartTime > 0 && filter.EndTime > 0 && filter.StartTime >= filter.EndTime {
+		return filter, fmt.Errorf("start_time must be before end_time")
+	}
+
+	if v := query.Get("api_key_id"); v != "" {
+		apiKeyID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid api_key_id")
+		}
+		id := uint(apiKeyID)
+		filter.APIKeyID = &id
+	}
+
+	filter.Status = query.Get("status")
+	switch filter.Status {
+	case "", RequestStatusSuccess, RequestStatusUpstreamError, RequestStatusFailed, RequestStatusRejected, RequestStatusCanceled:
+	default:
+		return filter, fmt.Errorf("invalid status")
+	}
+
+	filter.Model = query.Get("model")
+	filter.TraceID = query.Get("trace_id")
+	return filter, nil
+}
+
+// RequestRecordService 网关自身的调用记录，与同步的上游日志互补
+type RequestRecordService struct {
+	db *gorm.DB
+}
+
+func NewRequestRecordService(db *gorm.DB) *RequestRecordService {
+	return &RequestRecordService{
+		db: db,
+	}
+}
+
+// Save 写入调用记录
+func (s *RequestRecordService) Save(record *model.RequestRecord) error {
+	record.Model = truncate(record.Model, 128)
+	record.UpstreamModel = truncate(record.UpstreamModel, 128)
+	record.Error = truncate(record.Error, 512)
+	record.UserAgent = truncate(record.UserAgent, 255)
+	record.Referer = truncate(record.Referer, 255)
+	record.ClientIP = truncate(record.ClientIP, 64)
+	record.Browser = truncate(record.Browser, 64)
+	record.OS = truncate(record.OS, 64)
+	record.DeviceType = truncate(record.DeviceType, 32)
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	return s.db.Create(record).Error
+}
+
+// Link 将调用记录关联到本次同步到的上游日志，按模型和token数匹配尚未关联的日志，返回关联后的调用记录
+func (s *RequestRecordService) Link(userID uint, traceID string, lastSyncID uint) (*model.RequestRecord, error) {
+	var record model.RequestRecord
+	if err := s.db.Where("trace_id = ? AND user_id = ?", traceID, userID).First(&record).Error; err != nil {
+		return nil, err
+	}
+	if record.LogID > 0 {
+		return &record, nil
+	}
+
+	var log model.Log
+	if err := s.db.Select("id", "remote_log_id").
+		Where("user_id = ? AND remote_log_id > ?", userID, lastSyncID).
+		Where("model_name = ? AND prompt_tokens = ? AND completion_tokens = ?",
+			record.UpstreamModel, record.PromptTokens, record.CompletionTokens).
+		Where("NOT EXISTS (SELECT 1 FROM request_records r WHERE r.log_id = logs.id)").
+		Order("id ASC").
+		First(&log).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&record).Updates(map[string]interface{}{
+		"log_id":        log.ID,
+		"remote_log_id": log.RemoteLogID,
+	}).Error; err != nil {
+		return nil, err
+	}
+	record.LogID = log.ID
+	record.RemoteLogID = log.RemoteLogID
+	return &record, nil
+}
+
+// ListRecords 分页查询调用记录，按时间倒序
+func (s *RequestRecordService) ListRecords(filter RequestRecordFilter, page, pageSize int) ([]model.RequestRecord, int64, error) {
+	var total int64
+	if err := s.filterRecords(filter).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var records []model.RequestRecord
+	if err := s.filterRecords(filter).
+		Order("created_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&records).Error; err != nil {
+		return nil, 0, err
+	}
+	return records, total, nil
+}
+
+func (s *RequestRecordService) filterRecords(filter RequestRecordFilter) *gorm.DB {
+	query := s.db.Model(&model.RequestRecord{})
+	if filter.UserID > 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.APIKeyID != nil {
+		query = query.Where("api_key_id = ?", *filter.APIKeyID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Model != "" {
+		query = query.Where("model = ?", filter.Model)
+	}
+	if filter.TraceID != "" {
+		query = query.Where("trace_id = ?", filter.TraceID)
+	}
+	if filter.StartTime > 0 {
+		query = query.Where("created_at >= ?", time.Unix(filter.StartTime, 0))
+	}
+	if filter.EndTime > 0 {
+		query = query.Where("created_at < ?", time.Unix(filter.EndTime, 0))
+	}
+	return query
+}
+
+// CleanupBefore 分批删除指定时间之前的调用记录
+func (s *RequestRecordService) CleanupBefore(before time.Time) (int64, error) {
+	const batchSize = 1000
+
+	var deleted int64
+	for {
+		result := s.db.Where("created_at < ?", before).Limit(batchSize).Delete(&model.RequestRecord{})
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+		if result.RowsAffected < batchSize {
+			return deleted, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// StartCleanupLoop 每天清理超过保留天数的调用记录，与日志保留天数一致
+func (s *RequestRecordService) StartCleanupLoop(retentionDays int) {
+	if retentionDays <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			deleted, err := s.CleanupBefore(time.Now().AddDate(0, 0, -retentionDays))
+			if err != nil {
+				logger.Errorf("RequestRecordService cleanup failed: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				logger.Infof("RequestRecordService cleaned up %d request records", deleted)
+			}
+		}
+	}()
+}
@@ -0,0 +1,242 @@
+// internal/service/spending_service.go
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+
+	"llmapisrv/internal/model"
+	"llmapisrv/pkg/cache"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/money"
+)
+
+// 消费统计周期
+const (
+	SpendingPeriodDaily   = "daily"
+	SpendingPeriodMonthly = "monthly"
+)
+
+// SpendingLimitError 超出每日或每月消费上限
+type SpendingLimitError struct {
+	Period  string
+	ResetAt int64
+}
+
+func (e *SpendingLimitError) Error() string {
+	return fmt.Sprintf("%s spending limit exceeded, resets at %s",
+		e.Period, time.Unix(e.ResetAt, 0).Format(time.RFC3339))
+}
+
+// SpendingWindow 单个统计周期的消费情况
+type SpendingWindow struct {
+	Limit   money.Amount `json:"limit"` // 上限，quota 为0表示不限制
+	Spent   money.Amount `json:"spent"`
+	ResetAt int64        `json:"reset_at"` // 计数重置时间戳
+}
+
+// SpendingWindows 每日和每月消费情况
+type SpendingWindows struct {
+	Daily   SpendingWindow `json:"daily"`
+	Monthly SpendingWindow `json:"monthly"`
+}
+
+// SpendingStatus 用户及当前子密钥的消费情况
+type SpendingStatus struct {
+	User *SpendingWindows `json:"user"`
+	Key  *SpendingWindows `json:"key,omitempty"` // 子密钥调用时返回
+}
+
+// SpendingService 按日、按月统计消费，计数保存在 Redis
+// 用户消费由结算后同步的日志累加，子密钥消费在请求结束时按网关记录的用量累加
+type SpendingService struct {
+	db    *gorm.DB
+	cache *cache.RedisCache
+}
+
+func NewSpendingService(db *gorm.DB, cache *cache.RedisCache) *SpendingService {
+	return &SpendingService{
+		db:    db,
+		cache: cache,
+	}
+}
+
+// RecordUser 累加用户的消费，at 为日志产生时间
+func (s *SpendingService) RecordUser(userID uint, quota int64, at time.Time) {
+	s.record("user:"+strconv.FormatUint(uint64(userID), 10), quota, at)
+}
+
+// RecordKey 累加子密钥的消费
+func (s *SpendingService) RecordKey(apiKeyID uint, quota int64, at time.Time) {
+	s.record("key:"+strconv.FormatUint(uint64(apiKeyID), 10), quota, at)
+}
+
+func (s *SpendingService) record(subject string, quota int64, at time.Time) {
+	if quota <= 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, period := range []string{SpendingPeriodDaily, SpendingPeriodMonthly} {
+		// 计数保留到周期结束后一天，已过期周期的补同步日志不再计入
+		ttl := periodEnd(period, at).Unix() + 86400 - now.Unix()
+		if ttl <= 0 {
+			continue
+		}
+		if _, err := s.cache.IncrBy(spendingKey(subject, period, at), quota, int(ttl)); err != nil {
+			logger.Errorf("SpendingService record %s %s failed: %v", subject, period, err)
+		}
+	}
+}
+
+// Check 检查用户和子密钥是否超出消费上限，Redis 不可用时放行
+func (s *SpendingService) Check(ctx context.Context, userID, apiKeyID uint) error {
+	db := s.db.WithContext(ctx)
+	var user model.User
+	if err := db.Select("id", "daily_limit", "monthly_limit").First(&user, userID).Error; err != nil {
+		return err
+	}
+	if err := s.checkSubject(ctx, "user:"+strconv.FormatUint(uint64(userID), 10), user.DailyLimit, user.MonthlyLimit); err != nil {
+		return err
+	}
+
+	if apiKeyID == 0 {
+		return nil
+	}
+	var apiKey model.APIKey
+	if err := db.Select("id", "daily_limit", "monthly_limit").First(&apiKey, apiKeyID).Error; err != nil {
+		return err
+	}
+	return s.checkSubject(ctx, "key:"+strconv.FormatUint(uint64(apiKeyID), 10), apiKey.DailyLimit, apiKey.MonthlyLimit)
+}
+
+func (s *SpendingService) checkSubject(ctx context.Context, subject string, dailyLimit, monthlyLimit int64) error {
+	now := time.Now()
+	limits := map[string]int64{
+		SpendingPeriodDaily:   dailyLimit,
+		SpendingPeriodMonthly: monthlyLimit,
+	}
+	for _, period := range []string{SpendingPeriodDaily, SpendingPeriodMonthly} {
+		limit := limits[period]
+		if limit <= 0 {
+			continue
+		}
+		spent, err := s.spent(ctx, subject, period, now)
+		if err != nil {
+			logger.Errorf("SpendingService get %s %s spent failed: %v", subject, period, err)
+			continue
+		}
+		if spent >= limit {
+			return &SpendingLimitError{Period: period, ResetAt: periodEnd(period, now).Unix()}
+		}
+	}
+	return nil
+}
+
+// GetStatus 获取用户及子密钥的消费情况
+func (s *SpendingService) GetStatus(userID, apiKeyID uint) (*SpendingStatus, error) {
+	var user model.User
+	if err := s.db.Select("id", "daily_limit", "monthly_limit").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	status := &SpendingStatus{
+		User: s.windows("user:"+strconv.FormatUint(uint64(userID), 10), user.DailyLimit, user.MonthlyLimit),
+	}
+	if apiKeyID > 0 {
+		var apiKey model.APIKey
+		if err := s.db.Select("id", "daily_limit", "monthly_limit").First(&apiKey, apiKeyID).Error; err != nil {
+			return nil, err
+		}
+		status.Key = s.windows("key:"+strconv.FormatUint(uint64(apiKeyID), 10), apiKey.DailyLimit, apiKey.MonthlyLimit)
+	}
+	return status, nil
+}
+
+func (s *SpendingService) windows(subject string, dailyLimit, monthlyLimit int64) *SpendingWindows {
+	now := time.Now()
+	window := func(period string, limit int64) SpendingWindow {
+		spent, _ := s.spent(context.Background(), subject, period, now)
+		return SpendingWindow{
+			Limit:   money.FromQuota(limit),
+			Spent:   money.FromQuota(spent),
+			ResetAt: periodEnd(period, now).Unix(),
+		}
+	}
+	return &SpendingWindows{
+		Daily:   window(SpendingPeriodDaily, dailyLimit),
+		Monthly: window(SpendingPeriodMonthly, monthlyLimit),
+	}
+}
+
+// SetUserLimits 设置用户的每日、每月消费上限，0为不限制
+func (s *SpendingService) SetUserLimits(userID uint, dailyLimit, monthlyLimit int64) error {
+	if dailyLimit < 0 || monthlyLimit < 0 {
+		return fmt.Errorf("spending limit must not be negative")
+	}
+	return s.db.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"daily_limit":   dailyLimit,
+		"monthly_limit": monthlyLimit,
+		"updated_at":    time.Now(),
+	}).Error
+}
+
+// SetKeyLimits 设置子密钥的每日、每月消费上限，0为不限制
+func (s *SpendingService) SetKeyLimits(userID, apiKeyID uint, dailyLimit, monthlyLimit int64) (*model.APIKey, error) {
+	if dailyLimit < 0 || monthlyLimit < 0 {
+		return nil, fmt.Errorf("spending limit must not be negative")
+	}
+
+	var apiKey model.APIKey
+	if err := s.db.Where("id = ? AND user_id = ?", apiKeyID, userID).First(&apiKey).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("子密钥不存在")
+		}
+		return nil, err
+	}
+
+	if err := s.db.Model(&apiKey).Updates(map[string]interface{}{
+		"daily_limit":   dailyLimit,
+		"monthly_limit": monthlyLimit,
+		"updated_at":    time.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+	apiKey.DailyLimit = dailyLimit
+	apiKey.MonthlyLimit = monthlyLimit
+	return &apiKey, nil
+}
+
+func (s *SpendingService) spent(ctx context.Context, subject, period string, at time.Time) (int64, error) {
+	value, err := s.cache.WithContext(ctx).Get(spendingKey(subject, period, at))
+	if err == redis.Nil {
+		// 键不存在视为未消费
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// spendingKey 消费计数键，如 spend:user:1:daily:20260102、spend:key:3:monthly:202601
+func spendingKey(subject, period string, at time.Time) string {
+	if period == SpendingPeriodDaily {
+		return "spend:" + subject + ":daily:" + at.Format("20060102")
+	}
+	return "spend:" + subject + ":monthly:" + at.Format("200601")
+}
+
+// periodEnd 周期结束时间，按服务器本地时区计算
+func periodEnd(period string, at time.Time) time.Time {
+	y, m, d := at.Date()
+	if period == SpendingPeriodDaily {
+		return time.Date(y, m, d+1, 0, 0, 0, 0, at.Location())
+	}
+	return time.Date(y, m+1, 1, 0, 0, 0, 0, at.Location())
+}
@@ -0,0 +1,238 @@
+// internal/service/stats_service.go
+package service
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"llmapisrv/internal/model"
+	"llmapisrv/pkg/logger"
+	"llmapisrv/pkg/money"
+)
+
+// New API 日志类型
+const (
+	LogTypeConsume = 2 // 消费
+	LogTypeError   = 5 // 调用失败
+)
+
+// StatsDayFormat 统计日期格式
+const StatsDayFormat = "2006-01-02"
+
+// UsageStat 按用户或模型汇总的调用统计
+type UsageStat struct {
+	UserID           uint         `json:"user_id,omitempty"`
+	ModelName        string       `json:"model_name,omitempty"`
+	Requests         int64        `json:"requests"`
+	Errors           int64        `json:"errors"`
+	PromptTokens     int64        `json:"prompt_tokens"`
+	CompletionTokens int64        `json:"completion_tokens"`
+	Quota            int64        `json:"quota"`
+	AvgUseTime       float64      `json:"avg_use_time"`
+	Amount           money.Amount `json:"amount" gorm:"-"`
+}
+
+// RevenuePoint 每日消费
+type RevenuePoint struct {
+	Day      string       `json:"day"`
+	Requests int64        `json:"requests"`
+	Quota    int64        `json:"quota"`
+	Amount   money.Amount `json:"amount" gorm:"-"`
+}
+
+// ActivePoint 每日活跃用户和密钥数
+type ActivePoint struct {
+	Day         string `json:"day"`
+	ActiveUsers int64  `json:"active_users"`
+	ActiveKeys  int64  `json:"active_keys"` // 主密钥和子密钥分别计数
+}
+
+// StatsService 日志按天汇总到 usage_daily，管理后台统计基于汇总表查询，不扫描原始日志
+type StatsService struct {
+	db *gorm.DB
+}
+
+func NewStatsService(db *gorm.DB) *StatsService {
+	return &StatsService{
+		db: db,
+	}
+}
+
+// RollupDay 重新汇总指定日期（服务器时区）的日志，可重复执行
+func (s *StatsService) RollupDay(day time.Time) error {
+	y, m, d := day.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+	dayStr := start.Format(StatsDayFormat)
+
+	var rows []model.UsageDaily
+	if err := s.db.Model(&model.Log{}).
+		Select("user_id, api_key_id, model_name, COUNT(*) AS requests, "+
+			"SUM(CASE WHEN type = ? THEN 1 ELSE 0 END) AS errors, "+
+			"SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens, "+
+			"SUM(quota) AS quota, SUM(use_time) AS total_use_time", LogTypeError).
+		Where("created_at >= ? AND created_at < ?", start.Unix(), end.Unix()).
+		Where("type IN ?", []int{LogTypeConsume, LogTypeError}).
+		Group("user_id, api_key_id, model_name").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for i := range rows {
+		rows[i].Day = dayStr
+		rows[i].ModelName = truncate(rows[i].ModelName, 128)
+		rows[i].AvgUseTime = float64(rows[i].TotalUseTime) / float64(rows[i].Requests)
+		rows[i].UpdatedAt = now
+	}
+
+	return s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "day"}, {Name: "user_id"}, {Name: "api_key_id"}, {Name: "model_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"requests", "errors", "prompt_tokens", "completion_tokens", "quota",
+			"total_use_time", "avg_use_time", "updated_at",
+		}),
+	}).CreateInBatches(rows, 500).Error
+}
+
+// Rollup 汇总日期区间 [start, end] 内每一天的日志
+func (s *StatsService) Rollup(start, end time.Time) error {
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if err := s.RollupDay(day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartRollupLoop 定时汇总当天和前一天的日志，前一天用于补齐跨天同步的日志
+func (s *StatsService) StartRollupLoop(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			now := time.Now()
+			if err := s.Rollup(now.AddDate(0, 0, -1), now); err != nil {
+				logger.Errorf("StatsService rollup failed: %v", err)
+			}
+		}
+	}()
+}
+
+// TopUsers 消耗额度最多的用户，日期为 2006-01-02 格式的闭区间
+func (s *StatsService) TopUsers(startDay, endDay string, limit int) ([]UsageStat, error) {
+	var stats []UsageStat
+	if err := s.usageQuery(startDay, endDay).
+		Select("user_id, " + usageStatFields).
+		Group("user_id").
+		Order("quota DESC").
+		Limit(limit).
+		Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+	return fillUsageAmount(stats), nil
+}
+
+// TopModels 消耗额度最多的模型
+func (s *StatsService) TopModels(startDay, endDay string, limit int) ([]UsageStat, error) {
+	var stats []UsageStat
+	if err := s.usageQuery(startDay, endDay).
+		Select("model_name, " + usageStatFields).
+		Group("model_name").
+		Order("quota DESC").
+		Limit(limit).
+		Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+	return fillUsageAmount(stats), nil
+}
+
+// RevenueTrend 每日消费趋势，没有数据的日期补0
+func (s *StatsService) RevenueTrend(startDay, endDay string) ([]RevenuePoint, error) {
+	var points []RevenuePoint
+	if err := s.usageQuery(startDay, endDay).
+		Select("day, SUM(requests) AS requests, SUM(quota) AS quota").
+		Group("day").
+		Scan(&points).Error; err != nil {
+		return nil, err
+	}
+
+	byDay := make(map[string]RevenuePoint, len(points))
+	for _, p := range points {
+		byDay[p.Day] = p
+	}
+
+	var result []RevenuePoint
+	for _, day := range daysBetween(startDay, endDay) {
+		p := byDay[day]
+		p.Day = day
+		p.Amount = money.FromQuota(p.Quota)
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// ActiveKeys 每日活跃用户数和密钥数，没有数据的日期补0
+func (s *StatsService) ActiveKeys(startDay, endDay string) ([]ActivePoint, error) {
+	var points []ActivePoint
+	if err := s.usageQuery(startDay, endDay).
+		Select("day, COUNT(DISTINCT user_id) AS active_users, COUNT(DISTINCT user_id, api_key_id) AS active_keys").
+		Group("day").
+		Scan(&points).Error; err != nil {
+		return nil, err
+	}
+
+	byDay := make(map[string]ActivePoint, len(points))
+	for _, p := range points {
+		byDay[p.Day] = p
+	}
+
+	var result []ActivePoint
+	for _, day := range daysBetween(startDay, endDay) {
+		p := byDay[day]
+		p.Day = day
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+const usageStatFields = "SUM(requests) AS requests, SUM(errors) AS errors, " +
+	"SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens, " +
+	"SUM(quota) AS quota, SUM(total_use_time) / SUM(requests) AS avg_use_time"
+
+func (s *StatsService) usageQuery(startDay, endDay string) *gorm.DB {
+	return s.db.Model(&model.UsageDaily{}).Where("day >= ? AND day <= ?", startDay, endDay)
+}
+
+func fillUsageAmount(stats []UsageStat) []UsageStat {
+	for i := range stats {
+		stats[i].Amount = money.FromQuota(stats[i].Quota)
+	}
+	if stats == nil {
+		stats = []UsageStat{}
+	}
+	return stats
+}
+
+// daysBetween 列出 [startDay, endDay] 内的所有日期
+func daysBetween(startDay, endDay string) []string {
+	start, err := time.ParseInLocation(StatsDayFormat, startDay, time.Local)
+	if err != nil {
+		return nil
+	}
+	end, err := time.ParseInLocation(StatsDayFormat, endDay, time.Local)
+	if err != nil {
+		return nil
+	}
+
+	var days []string
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day.Format(StatsDayFormat))
+	}
+	return days
+}
@@ -19,14 +19,20 @@ type SyncService struct {
 	newAPIDB  *gorm.DB
 	config    *config.Config
 	cache     *cache.RedisCache
+	authCache *AuthCacheService
+	notifier  *NotificationService
+	spending  *SpendingService
 }
 
-func NewSyncService(gatewayDB, newAPIDB *gorm.DB, config *config.Config, cache *cache.RedisCache) *SyncService {
+func NewSyncService(gatewayDB, newAPIDB *gorm.DB, config *config.Config, cache *cache.RedisCache, authCache *AuthCacheService, notifier *NotificationService, spending *SpendingService) *SyncService {
 	return &SyncService{
 		gatewayDB: gatewayDB,
 		newAPIDB:  newAPIDB,
 		config:    config,
 		cache:     cache,
+		authCache: authCache,
+		notifier:  notifier,
+		spending:  spending,
 	}
 }
 
@@ -62,7 +68,12 @@ func (s *SyncService) SyncUserByAPIKey(apiKey string) (*model.User, error) {
 		}
 	} else if result.Error != nil {
 		return nil, result.Error
-	} else if user.RemainQuota != newAPIToken.RemainQuota {
+	} else if user.RemainQuota != newAPIToken.RemainQuota ||
+		user.Status != newAPIToken.Status ||
+		user.ExpiredTime != newAPIToken.ExpiredTime {
+		// 状态或过期时间变化时需要清除鉴权缓存
+		authChanged := user.Status != newAPIToken.Status || user.ExpiredTime != newAPIToken.ExpiredTime
+
 		// 更新现有用户
 		user.TokenID = newAPIToken.ID
 		user.RemainQuota = newAPIToken.RemainQuota
@@ -74,9 +85,16 @@ func (s *SyncService) SyncUserByAPIKey(apiKey string) (*model.User, error) {
 		if err := s.gatewayDB.Save(&user).Error; err != nil {
 			return nil, err
 		}
+		if authChanged {
+			s.authCache.PurgeUser(&user)
+		}
 		// NewNewAPIService(s.config, s.cache).GetBillingInfo("sk-"+apiKey, false)
 	}
 
+	// 余额或到期时间越过用户设置的阈值时发出通知，鉴权缓存未命中时也会走到这里，异步检查不增加鉴权耗时
+	checked := user
+	go s.notifier.CheckUser(&checked)
+
 	return &user, nil
 }
 
@@ -144,6 +162,9 @@ func (s *SyncService) SyncLogsByTokenID(tokenID uint, lastSyncID uint) error {
 		if err := s.gatewayDB.Create(&log).Error; err != nil {
 			return err
 		}
+
+		// 结算后的消费计入每日、每月消费统计
+		s.spending.RecordUser(user.ID, log.Quota, time.Unix(log.CreatedAt, 0))
 	}
 
 	// 更新同步状态
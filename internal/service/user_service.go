@@ -8,21 +8,27 @@ import (
 
 	"llmapisrv/internal/model"
 	"llmapisrv/pkg/cache"
+	"llmapisrv/pkg/logger"
 )
 
+// New API 中令牌过期后的状态值，延长有效期时恢复为启用
+const tokenStatusExpired = 3
+
 type UserService struct {
 	gatewayDB   *gorm.DB
 	newAPIDB    *gorm.DB
 	cache       *cache.RedisCache
 	syncService *SyncService
+	authCache   *AuthCacheService
 }
 
-func NewUserService(gatewayDB, newAPIDB *gorm.DB, cache *cache.RedisCache, syncService *SyncService) *UserService {
+func NewUserService(gatewayDB, newAPIDB *gorm.DB, cache *cache.RedisCache, syncService *SyncService, authCache *AuthCacheService) *UserService {
 	return &UserService{
 		gatewayDB:   gatewayDB,
 		newAPIDB:    newAPIDB,
 		cache:       cache,
 		syncService: syncService,
+		authCache:   authCache,
 	}
 }
 
@@ -69,14 +75,39 @@ func (s *UserService) GetUserByID(id uint) (*model.User, error) {
 
 // AddQuota 添加用户额度
 func (s *UserService) AddQuota(userID uint, quota int64) error {
-	// 在本地数据库中添加额度
+	return s.CreditQuota(userID, func(tx *gorm.DB) (*Credit, error) {
+		return &Credit{Quota: quota, Source: "admin"}, nil
+	})
+}
+
+// Credit 需要同步到 New API 的额度和有效期变更
+type Credit struct {
+	Quota      int64  // 增加的额度
+	ExtendDays int    // 延长的有效天数
+	Source     string // 额度来源，如 order:<订单号>、redemption_code:<兑换码ID>，同步失败时用于人工对账
+}
+
+// CreditQuota 在同一个网关事务中执行fn并为用户增加fn返回的额度和有效期，提交后再同步到 New API
+// fn 返回错误时整个事务回滚，本地与 New API 的额度、有效期都不会变更
+// 网关事务先提交，兑换码、订单状态因此先于 New API 变更，重试时会被识别为已使用、已支付，不会重复加额度。
+// New API 更新失败时回退本地额度和有效期，并按 Credit.Source 记录错误日志，需人工补发；不自动重试，
+// 因为提交失败时无法确定 New API 是否已生效，重试可能重复加额度
+func (s *UserService) CreditQuota(userID uint, fn func(tx *gorm.DB) (*Credit, error)) error {
 	txg := s.gatewayDB.Begin()
-	txn := s.newAPIDB.Begin()
+	if txg.Error != nil {
+		return txg.Error
+	}
+
+	credit, err := fn(txg)
+	if err != nil {
+		txg.Rollback()
+		return err
+	}
 
 	// 更新本地用户额度
 	if err := txg.Model(&model.User{}).
 		Where("id = ?", userID).
-		Update("remain_quota", gorm.Expr("remain_quota + ?", quota)).
+		Update("remain_quota", gorm.Expr("remain_quota + ?", credit.Quota)).
 		Error; err != nil {
 		txg.Rollback()
 		return err
@@ -89,27 +120,82 @@ func (s *UserService) AddQuota(userID uint, quota int64) error {
 		return err
 	}
 
-	// 更新New API数据库中的额度
-	if err := txn.Exec(`
-        UPDATE tokens SET remain_quota = remain_quota + ?
-        WHERE id = ?
-    `, quota, user.TokenID).Error; err != nil {
-		txn.Rollback()
-		txg.Rollback()
-		return err
+	// 计算延长后的有效期，永不过期的账户保持不变
+	oldExpiredTime := user.ExpiredTime
+	expiredTime := user.ExpiredTime
+	if credit.ExtendDays > 0 && user.ExpiredTime > 0 {
+		expiredTime = max(user.ExpiredTime, time.Now().Unix()) + int64(credit.ExtendDays)*86400
+		if err := txg.Model(&model.User{}).
+			Where("id = ?", userID).
+			Updates(map[string]interface{}{
+				"expired_time": expiredTime,
+				"status":       gorm.Expr("CASE WHEN status = ? THEN 1 ELSE status END", tokenStatusExpired),
+			}).Error; err != nil {
+			txg.Rollback()
+			return err
+		}
 	}
 
-	err := txn.Commit().Error
-	if err != nil {
-		txn.Rollback()
-		txg.Rollback()
+	if err := txg.Commit().Error; err != nil {
+		return err
+	}
+	// 清除鉴权缓存
+	defer s.authCache.PurgeUser(&user)
+
+	// 更新New API数据库中的额度和有效期
+	if err := s.creditNewAPI(user.TokenID, credit.Quota, expiredTime, expiredTime != oldExpiredTime); err != nil {
+		logger.Errorf("UserService credit new api failed, source: %s, user_id: %d, token_id: %d, quota: %d, expired_time: %d, err: %v",
+			credit.Source, userID, user.TokenID, credit.Quota, expiredTime, err)
+
+		// 回退本地额度和有效期，与 New API 保持一致
+		if err := s.gatewayDB.Model(&model.User{}).
+			Where("id = ?", userID).
+			Updates(map[string]interface{}{
+				"remain_quota": gorm.Expr("remain_quota - ?", credit.Quota),
+				"expired_time": oldExpiredTime,
+			}).Error; err != nil {
+			logger.Errorf("UserService revert local credit failed, source: %s, user_id: %d, err: %v", credit.Source, userID, err)
+		}
 		return err
 	}
-	txg.Commit()
 
 	return nil
 }
 
+// creditNewAPI 在一个 New API 事务中增加令牌额度，extend 为 true 时同时更新有效期并恢复已过期的令牌
+func (s *UserService) creditNewAPI(tokenID uint, quota, expiredTime int64, extend bool) error {
+	return s.newAPIDB.Transaction(func(txn *gorm.DB) error {
+		if err := txn.Exec(`
+            UPDATE tokens SET remain_quota = remain_quota + ?
+            WHERE id = ?
+        `, quota, tokenID).Error; err != nil {
+			return err
+		}
+		if !extend {
+			return nil
+		}
+		return txn.Exec(`
+            UPDATE tokens SET expired_time = ?,
+                status = CASE WHEN status = ? THEN 1 ELSE status END
+            WHERE id = ?
+        `, expiredTime, tokenStatusExpired, tokenID).Error
+	})
+}
+
+// GetModelGrants 获取用户已解锁的模型及其过期时间
+func (s *UserService) GetModelGrants(userID uint) (map[string]int64, error) {
+	var grants []model.UserModelGrant
+	if err := s.gatewayDB.Where("user_id = ?", userID).Find(&grants).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int64, len(grants))
+	for _, grant := range grants {
+		result[grant.ModelName] = grant.ExpiredTime
+	}
+	return result, nil
+}
+
 // UpdateUserStatus 更新用户状态
 func (s *UserService) UpdateUserStatus(userID uint, status int) error {
 	// 在本地数据库中更新状态
@@ -140,5 +226,12 @@ func (s *UserService) UpdateUserStatus(userID uint, status int) error {
 		return err
 	}
 
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	// 清除鉴权缓存，使状态变更立即生效
+	s.authCache.PurgeUser(&user)
+
+	return nil
 }
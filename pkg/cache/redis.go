@@ -10,6 +10,7 @@ import (
 
 type RedisCache struct {
 	client *redis.Client
+	ctx    context.Context
 }
 
 func NewRedisCache(client *redis.Client) *RedisCache {
@@ -18,15 +19,35 @@ func NewRedisCache(client *redis.Client) *RedisCache {
 	}
 }
 
+// WithContext 返回使用指定上下文执行命令的副本，用于将 Redis 调用关联到请求的链路
+func (c *RedisCache) WithContext(ctx context.Context) *RedisCache {
+	return &RedisCache{
+		client: c.client,
+		ctx:    ctx,
+	}
+}
+
+func (c *RedisCache) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// Ping 检查连接
+func (c *RedisCache) Ping() error {
+	return c.client.Ping(c.context()).Err()
+}
+
 // Get 获取缓存
 func (c *RedisCache) Get(key string) (string, error) {
-	return c.client.Get(context.Background(), key).Result()
+	return c.client.Get(c.context(), key).Result()
 }
 
 // Set 设置缓存
 func (c *RedisCache) Set(key string, value string, expireSeconds int) error {
 	return c.client.Set(
-		context.Background(),
+		c.context(),
 		key,
 		value,
 		time.Duration(expireSeconds)*time.Second,
@@ -35,12 +56,12 @@ func (c *RedisCache) Set(key string, value string, expireSeconds int) error {
 
 // Delete 删除缓存
 func (c *RedisCache) Delete(key string) error {
-	return c.client.Del(context.Background(), key).Err()
+	return c.client.Del(c.context(), key).Err()
 }
 
 // Exists 检查缓存是否存在
 func (c *RedisCache) Exists(key string) (bool, error) {
-	result, err := c.client.Exists(context.Background(), key).Result()
+	result, err := c.client.Exists(c.context(), key).Result()
 	if err != nil {
 		return false, err
 	}
@@ -49,5 +70,51 @@ func (c *RedisCache) Exists(key string) (bool, error) {
 
 // TTL 获取缓存剩余时间
 func (c *RedisCache) TTL(key string) (time.Duration, error) {
-	return c.client.TTL(context.Background(), key).Result()
+	return c.client.TTL(c.context(), key).Result()
+}
+
+// Publish 发布消息到频道
+func (c *RedisCache) Publish(channel string, message string) error {
+	return c.client.Publish(c.context(), channel, message).Err()
+}
+
+// Subscribe 订阅频道
+func (c *RedisCache) Subscribe(channels ...string) *redis.PubSub {
+	return c.client.Subscribe(context.Background(), channels...)
+}
+
+// Incr 自增计数，首次创建时设置过期时间
+func (c *RedisCache) Incr(key string, expireSeconds int) (int64, error) {
+	ctx := c.context()
+	count, err := c.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 && expireSeconds > 0 {
+		c.client.Expire(ctx, key, time.Duration(expireSeconds)*time.Second)
+	}
+	return count, nil
+}
+
+// IncrBy 按指定值自增，首次创建时设置过期时间
+func (c *RedisCache) IncrBy(key string, value int64, expireSeconds int) (int64, error) {
+	ctx := c.context()
+	count, err := c.client.IncrBy(ctx, key, value).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == value && expireSeconds > 0 {
+		c.client.Expire(ctx, key, time.Duration(expireSeconds)*time.Second)
+	}
+	return count, nil
+}
+
+// SetNX 键不存在时设置缓存，返回是否设置成功
+func (c *RedisCache) SetNX(key string, value string, expireSeconds int) (bool, error) {
+	return c.client.SetNX(
+		c.context(),
+		key,
+		value,
+		time.Duration(expireSeconds)*time.Second,
+	).Result()
 }
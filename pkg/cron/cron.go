@@ -2,11 +2,14 @@
 package cron
 
 import (
+	"context"
 	"log"
+	"time"
 
 	"github.com/robfig/cron/v3"
 
 	"llmapisrv/internal/service"
+	"llmapisrv/pkg/notifier"
 )
 
 // CronManager 定时任务管理器
@@ -15,6 +18,7 @@ type CronManager struct {
 	logService   *service.LogService
 	modelService *service.ModelService
 	syncService  *service.SyncService
+	notifier     *notifier.Notifier
 }
 
 // NewCronManager 创建定时任务管理器
@@ -22,6 +26,7 @@ func NewCronManager(
 	logService *service.LogService,
 	modelService *service.ModelService,
 	syncService *service.SyncService,
+	notifier *notifier.Notifier,
 ) *CronManager {
 	c := cron.New(cron.WithSeconds())
 	return &CronManager{
@@ -29,6 +34,7 @@ func NewCronManager(
 		logService:   logService,
 		modelService: modelService,
 		syncService:  syncService,
+		notifier:     notifier,
 	}
 }
 
@@ -88,6 +94,7 @@ func (m *CronManager) syncUsers() {
 	log.Println("Starting user sync")
 	if err := m.syncService.SyncAllUsers(); err != nil {
 		log.Printf("Error syncing users: %v", err)
+		m.notifySyncFailed("sync_users", err)
 	}
 	log.Println("Finished user sync")
 }
@@ -97,6 +104,20 @@ func (m *CronManager) syncLogs() {
 	log.Println("Starting logs sync")
 	if err := m.syncService.SyncAllLogs(); err != nil {
 		log.Printf("Error syncing logs: %v", err)
+		m.notifySyncFailed("sync_logs", err)
 	}
 	log.Println("Finished logs sync")
 }
+
+// notifySyncFailed 同步失败时邮件告警管理员
+func (m *CronManager) notifySyncFailed(task string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := m.notifier.NotifyAdmins(ctx, notifier.EventSyncFailed, map[string]interface{}{
+		"Task":  task,
+		"Time":  time.Now().Format("2006-01-02 15:04:05"),
+		"Error": err.Error(),
+	}); err != nil {
+		log.Printf("Failed to notify admins: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+// pkg/export/export.go
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 导出格式
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// RowWriter 逐行写出表格，写完后必须调用 Close
+type RowWriter interface {
+	// Write 写出一行，单元格支持 string、整数、浮点数和 bool
+	Write(row []interface{}) error
+	Close() error
+}
+
+// NewRowWriter 按格式创建行写入器
+func NewRowWriter(format string, w io.Writer) (RowWriter, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w), nil
+	case FormatXLSX:
+		return NewXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// ContentType 导出格式对应的 Content-Type
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// CSVWriter CSV 行写入器，开头写入 UTF-8 BOM 以便 Excel 正确识别编码
+type CSVWriter struct {
+	out     io.Writer
+	w       *csv.Writer
+	started bool
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{
+		out: w,
+		w:   csv.NewWriter(w),
+	}
+}
+
+func (c *CSVWriter) Write(row []interface{}) error {
+	if !c.started {
+		c.started = true
+		if _, err := io.WriteString(c.out, "\xEF\xBB\xBF"); err != nil {
+			return err
+		}
+	}
+
+	record := make([]string, len(row))
+	for i, v := range row {
+		record[i] = formatCell(v)
+		if _, ok := v.(string); ok {
+			record[i] = escapeFormula(record[i])
+		}
+	}
+	return c.w.Write(record)
+}
+
+func (c *CSVWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// formatCell 单元格转为字符串
+func formatCell(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case int:
+		return strconv.Itoa(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case uint:
+		return strconv.FormatUint(uint64(val), 10)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// escapeFormula 文本以公式字符开头时加单引号，避免在表格软件中被当作公式执行
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
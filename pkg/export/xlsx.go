@@ -0,0 +1,111 @@
+// pkg/export/xlsx.go
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// xlsx 固定部分，工作表内容单独流式写入
+var xlsxStaticFiles = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// XLSXWriter 最小化的 xlsx 流式写入器，只包含一个工作表，文本使用内联字符串，不在内存中保留行数据
+type XLSXWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+}
+
+func NewXLSXWriter(w io.Writer) (*XLSXWriter, error) {
+	zw := zip.NewWriter(w)
+	for _, f := range xlsxStaticFiles {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(fw, f.content); err != nil {
+			return nil, err
+		}
+	}
+
+	// 工作表必须最后写入，zip 条目创建后只能顺序写
+	fw, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	sheet := bufio.NewWriter(fw)
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	return &XLSXWriter{
+		zw:    zw,
+		sheet: sheet,
+	}, nil
+}
+
+func (x *XLSXWriter) Write(row []interface{}) error {
+	x.sheet.WriteString("<row>")
+	for _, v := range row {
+		switch v.(type) {
+		case int, int64, uint, float64:
+			x.sheet.WriteString("<c><v>" + formatCell(v) + "</v></c>")
+		case bool:
+			value := "0"
+			if v.(bool) {
+				value = "1"
+			}
+			x.sheet.WriteString(`<c t="b"><v>` + value + "</v></c>")
+		default:
+			x.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(x.sheet, []byte(stripInvalidXML(formatCell(v)))); err != nil {
+				return err
+			}
+			x.sheet.WriteString("</t></is></c>")
+		}
+	}
+	_, err := x.sheet.WriteString("</row>")
+	return err
+}
+
+func (x *XLSXWriter) Close() error {
+	x.sheet.WriteString("</sheetData></worksheet>")
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+// stripInvalidXML 移除 XML 1.0 不允许的控制字符
+func stripInvalidXML(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, s)
+}
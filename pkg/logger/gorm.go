@@ -0,0 +1,25 @@
+// pkg/logger/gorm.go
+package logger
+
+import (
+	"fmt"
+	"time"
+
+	gormLogger "gorm.io/gorm/logger"
+)
+
+// gormWriter 将 gorm 的输出写入 zap，与其他日志一样经过脱敏
+type gormWriter struct{}
+
+func (gormWriter) Printf(format string, args ...interface{}) {
+	log.Warn(fmt.Sprintf(format, args...))
+}
+
+// NewGormLogger 创建 gorm 日志，只记录慢查询和错误，SQL 中的参数经过脱敏后输出
+func NewGormLogger() gormLogger.Interface {
+	return gormLogger.New(gormWriter{}, gormLogger.Config{
+		SlowThreshold:             200 * time.Millisecond,
+		LogLevel:                  gormLogger.Warn,
+		IgnoreRecordNotFoundError: true,
+	})
+}
@@ -10,10 +10,14 @@ import (
 	"gopkg.in/natefinch/lumberjack.v2"
 
 	"llmapisrv/config"
+	"llmapisrv/pkg/tracing"
 )
 
 const traceIDKey = "trace_id"
 
+// traceIDCtxKey 上下文中保存 trace_id 的键
+type traceIDCtxKey struct{}
+
 var log *zap.Logger
 
 // Setup 初始化日志
@@ -71,8 +75,27 @@ func Setup(cfg config.Logger) {
 		),
 	)
 
+	// 审计日志和内容采集始终使用同一套规则，规则有误时使用默认规则
+	var redactErr error
+	if r, err := NewRedactor(cfg.Redaction); err != nil {
+		redactErr = err
+	} else {
+		redactor = r
+	}
+	if r, err := NewContentRedactor(cfg.Redaction); err == nil {
+		contentRedactor = r
+	}
+
+	// 所有日志输出先经过脱敏
+	if !cfg.Redaction.Disabled {
+		core = &redactCore{Core: core, redactor: redactor}
+	}
+
 	// 添加调用者信息
 	log = zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
+	if redactErr != nil {
+		log.Warn("invalid logger redaction config, using defaults", zap.Error(redactErr))
+	}
 }
 
 // Debug 调试级别日志
@@ -112,11 +135,24 @@ func Field(key string, value interface{}) zap.Field {
 }
 
 func getTraceID(ctx context.Context) string {
-	traceID, ok := ctx.Value(traceIDKey).(string)
-	if !ok || traceID == "" { // Handle missing or empty trace ID
-		return "no-trace-id" // Or generate a new one if preferred
+	if traceID, ok := ctx.Value(traceIDCtxKey{}).(string); ok && traceID != "" {
+		return traceID
+	}
+	// 未写入时使用当前 span 的 trace id
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		return traceID
 	}
-	return traceID
+	return "no-trace-id"
+}
+
+// WithTraceID 将 trace_id 写入上下文
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDCtxKey{}, traceID)
+}
+
+// TraceID 获取上下文中的 trace_id
+func TraceID(ctx context.Context) string {
+	return getTraceID(ctx)
 }
 
 // DebugWithCtx adds a trace_id field from the context to the log message.
@@ -0,0 +1,495 @@
+// pkg/logger/redact.go
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"llmapisrv/config"
+	"llmapisrv/pkg/util"
+)
+
+// 默认记录的请求头，未列出的请求头不输出
+var defaultHeaderAllowlist = []string{
+	"Accept", "Content-Type", "Content-Length", "User-Agent", "Referer",
+	"X-Forwarded-For", "X-Real-IP", "X-Request-ID", "Authorization",
+}
+
+// 默认脱敏的请求头，即使在允许列表中也只输出首尾几位
+var defaultHeaderDenylist = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Admin-Key",
+}
+
+// 密钥类字段名，日志、审计和内容采集都会脱敏
+var secretJSONPaths = []string{
+	"api_key", "apikey", "token", "access_token", "password", "secret", "admin_key", "authorization",
+}
+
+// 默认脱敏的 JSON 路径，不以 $. 开头的路径匹配任意层级
+var defaultJSONPaths = append(append([]string{}, secretJSONPaths...), "image_data", "prompt", "messages[*].content")
+
+// 内容采集保留对话内容，只脱敏密钥类字段和图片数据
+var contentJSONPaths = append(append([]string{}, secretJSONPaths...), "image_data")
+
+// redactRule 脱敏规则，label 为空时只保留首尾几位（有分组时只处理最后一个分组），否则替换为 [REDACTED:label]
+type redactRule struct {
+	pattern *regexp.Regexp
+	label   string
+	valid   func(string) bool // 匹配后的额外校验，如银行卡号的 Luhn 校验
+}
+
+// 默认的密钥格式
+var defaultRules = []redactRule{
+	{pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), label: "private_key"},
+	{pattern: regexp.MustCompile(`\beyJ[\w-]{8,}\.[\w-]{8,}\.[\w-]{8,}`)},      // JWT
+	{pattern: regexp.MustCompile(`(?i)\b(?:bearer|basic)\s+([\w.~+/=-]{8,})`)}, // 授权头
+	{pattern: regexp.MustCompile(`\b(?:sk|pk|rk)-[A-Za-z0-9_-]{16,}`)},         // OpenAI 格式的密钥
+	{pattern: regexp.MustCompile(`\badm-[0-9a-f]{16,}`)},                       // 管理员 token
+	{pattern: regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`)},                      // AWS access key
+	{pattern: regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},            // GitHub token
+	{pattern: regexp.MustCompile(`\b[A-Za-z0-9]{48}\b`)},                       // New API 密钥和子密钥
+	{pattern: regexp.MustCompile(`(?i)\b(?:passwd|pwd|api[_-]?key|access[_-]?key|token|password|secret|admin_key)["']?\s*[:=]\s*["']?([^\s"',;&]{4,})`)},
+}
+
+// 个人信息规则，只用于内容采集，身份证号需在银行卡号之前处理
+var piiRules = []redactRule{
+	{pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), label: "email"},
+	{pattern: regexp.MustCompile(`\b[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`), label: "id_card"},
+	{pattern: regexp.MustCompile(`(?:\+86[- ]?|\b(?:86[- ]?)?)1[3-9]\d{9}\b`), label: "phone"},
+	{pattern: regexp.MustCompile(`\+[1-9]\d{7,14}\b`), label: "phone"},
+	// 银行卡号需通过 Luhn 校验才替换，避免误伤普通长数字
+	{pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), label: "card", valid: luhnValid},
+}
+
+// Redactor 脱敏规则：请求头允许/禁止列表、JSON 路径和正则，日志、审计和内容采集共用
+type Redactor struct {
+	headerAllow map[string]bool
+	headerDeny  map[string]bool
+	rootPaths   [][]string // 以 $. 开头，从根节点匹配
+	anyPaths    [][]string // 匹配任意层级
+	fieldKeys   map[string]bool
+	rules       []redactRule
+}
+
+// 未调用 Setup 时使用默认规则
+var (
+	redactor        = mustRedactor(NewRedactor(config.LogRedaction{}))
+	contentRedactor = mustRedactor(NewContentRedactor(config.LogRedaction{}))
+)
+
+// NewRedactor 根据配置创建日志脱敏规则，列表为空时使用默认值
+func NewRedactor(cfg config.LogRedaction) (*Redactor, error) {
+	return newRedactor(cfg, nil)
+}
+
+// NewContentRedactor 创建内容采集的脱敏规则，保留对话内容，额外脱敏个人信息
+func NewContentRedactor(cfg config.LogRedaction) (*Redactor, error) {
+	cfg.JSONPaths = contentJSONPaths
+	return newRedactor(cfg, piiRules)
+}
+
+func newRedactor(cfg config.LogRedaction, extraRules []redactRule) (*Redactor, error) {
+	headerAllow, headerDeny := cfg.HeaderAllowlist, cfg.HeaderDenylist
+	if len(headerAllow) == 0 {
+		headerAllow = defaultHeaderAllowlist
+	}
+	if len(headerDeny) == 0 {
+		headerDeny = defaultHeaderDenylist
+	}
+	jsonPaths := cfg.JSONPaths
+	if len(jsonPaths) == 0 {
+		jsonPaths = defaultJSONPaths
+	}
+
+	r := &Redactor{
+		headerAllow: lowerSet(headerAllow),
+		headerDeny:  lowerSet(headerDeny),
+		fieldKeys:   make(map[string]bool),
+	}
+	for k := range r.headerDeny {
+		r.fieldKeys[k] = true
+	}
+	for _, p := range jsonPaths {
+		segments := parseJSONPath(p)
+		if len(segments) == 0 {
+			continue
+		}
+		if strings.HasPrefix(p, "$.") {
+			r.rootPaths = append(r.rootPaths, segments)
+			continue
+		}
+		r.anyPaths = append(r.anyPaths, segments)
+		if len(segments) == 1 {
+			// 单个键名同时用于匹配日志字段名
+			r.fieldKeys[segments[0]] = true
+		}
+	}
+	r.rules = append(r.rules, defaultRules...)
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %v", p, err)
+		}
+		r.rules = append(r.rules, redactRule{pattern: re})
+	}
+	r.rules = append(r.rules, extraRules...)
+	return r, nil
+}
+
+func mustRedactor(r *Redactor, err error) *Redactor {
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// String 按正则脱敏文本，JSON 文本同时按路径脱敏
+func (r *Redactor) String(s string) string {
+	trimmed := strings.TrimSpace(s)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var v interface{}
+		if err := json.Unmarshal([]byte(trimmed), &v); err == nil {
+			s = util.ToJSONString(r.walk(v, nil))
+		}
+	}
+	return r.maskPatterns(s)
+}
+
+// Body 脱敏请求体，超过 maxLen 时截断，非 JSON 内容只记录长度
+func (r *Redactor) Body(body []byte, maxLen int) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Sprintf("[non-json body, %d bytes]", len(body))
+	}
+	result := r.maskPatterns(util.ToJSONString(r.walk(v, nil)))
+	if maxLen > 0 && len(result) > maxLen {
+		result = result[:maxLen] + "...(truncated)"
+	}
+	return result
+}
+
+// Text 只按正则脱敏文本
+func (r *Redactor) Text(s string) string {
+	return r.maskPatterns(s)
+}
+
+// JSON 按路径和正则脱敏 JSON 内容，不截断，非 JSON 内容按文本处理
+func (r *Redactor) JSON(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return r.maskPatterns(string(body))
+	}
+	return r.maskPatterns(util.ToJSONString(r.walk(v, nil)))
+}
+
+// Query 按与 JSON 相同的规则脱敏 URL 查询参数
+func (r *Redactor) Query(rawQuery string) interface{} {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return fmt.Sprintf("[invalid query, %d bytes]", len(rawQuery))
+	}
+
+	data := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		data[k] = strings.Join(v, ",")
+	}
+	return r.walk(data, nil)
+}
+
+// Headers 按允许列表过滤请求头，禁止列表中的请求头只保留首尾几位
+func (r *Redactor) Headers(h http.Header) map[string]string {
+	result := make(map[string]string, len(h))
+	for k, values := range h {
+		key := strings.ToLower(k)
+		if !r.headerAllow[key] {
+			continue
+		}
+		value := strings.Join(values, ", ")
+		if r.headerDeny[key] {
+			value = maskHeader(value)
+		} else {
+			value = r.maskPatterns(value)
+		}
+		result[k] = value
+	}
+	return result
+}
+
+func (r *Redactor) walk(v interface{}, path []string) interface{} {
+	if r.matchPath(path) {
+		return maskValue(v)
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = r.walk(item, append(path[:len(path):len(path)], strings.ToLower(k)))
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = r.walk(item, append(path[:len(path):len(path)], "*"))
+		}
+		return val
+	case string:
+		return r.maskPatterns(val)
+	default:
+		return v
+	}
+}
+
+func (r *Redactor) matchPath(path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	for _, p := range r.rootPaths {
+		if len(p) == len(path) && segmentsEqual(p, path) {
+			return true
+		}
+	}
+	for _, p := range r.anyPaths {
+		if len(p) <= len(path) && segmentsEqual(p, path[len(path)-len(p):]) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskPatterns 脱敏匹配正则的内容
+func (r *Redactor) maskPatterns(s string) string {
+	for _, rule := range r.rules {
+		matches := rule.pattern.FindAllStringSubmatchIndex(s, -1)
+		if matches == nil {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if n := len(m); rule.label == "" && n > 2 && m[n-2] >= 0 {
+				start, end = m[n-2], m[n-1]
+			}
+			if rule.valid != nil && !rule.valid(s[start:end]) {
+				continue
+			}
+			b.WriteString(s[last:start])
+			if rule.label != "" {
+				b.WriteString("[REDACTED:" + rule.label + "]")
+			} else {
+				b.WriteString(util.MaskSecret(s[start:end]))
+			}
+			last = end
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+	}
+	return s
+}
+
+// field 脱敏单个日志字段
+func (r *Redactor) field(f zapcore.Field) zapcore.Field {
+	if r.fieldKeys[strings.ToLower(f.Key)] {
+		if f.Type == zapcore.StringType {
+			return zap.String(f.Key, maskHeader(f.String))
+		}
+		return zap.String(f.Key, "[REDACTED]")
+	}
+
+	switch f.Type {
+	case zapcore.StringType:
+		f.String = r.String(f.String)
+	case zapcore.ByteStringType:
+		if b, ok := f.Interface.([]byte); ok {
+			return zap.String(f.Key, r.String(string(b)))
+		}
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok && err != nil {
+			return zap.String(f.Key, r.maskPatterns(err.Error()))
+		}
+	case zapcore.StringerType:
+		if s, ok := f.Interface.(fmt.Stringer); ok && s != nil {
+			return zap.String(f.Key, r.maskPatterns(s.String()))
+		}
+	case zapcore.ReflectType:
+		data, err := json.Marshal(f.Interface)
+		if err != nil {
+			return f
+		}
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return f
+		}
+		return zap.Any(f.Key, r.walk(v, nil))
+	}
+	return f
+}
+
+func (r *Redactor) fields(fields []zapcore.Field) []zapcore.Field {
+	if len(fields) == 0 {
+		return fields
+	}
+	result := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		result[i] = r.field(f)
+	}
+	return result
+}
+
+// redactCore 在写入前对消息和字段脱敏，包装所有输出
+type redactCore struct {
+	zapcore.Core
+	redactor *Redactor
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{
+		Core:     c.Core.With(c.redactor.fields(fields)),
+		redactor: c.redactor,
+	}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = c.redactor.maskPatterns(ent.Message)
+	return c.Core.Write(ent, c.redactor.fields(fields))
+}
+
+// Headers 创建按脱敏规则过滤后的请求头字段
+func Headers(key string, h http.Header) zap.Field {
+	return zap.Any(key, redactor.Headers(h))
+}
+
+// RedactBody 按日志脱敏规则处理请求体，用于访问日志和审计日志
+func RedactBody(body []byte, maxLen int) string {
+	return redactor.Body(body, maxLen)
+}
+
+// RedactQuery 按日志脱敏规则处理 URL 查询参数
+func RedactQuery(rawQuery string) interface{} {
+	return redactor.Query(rawQuery)
+}
+
+// RedactText 按日志脱敏规则处理文本
+func RedactText(s string) string {
+	return redactor.Text(s)
+}
+
+// RedactContent 按内容采集规则处理请求体，保留对话内容
+func RedactContent(body []byte) string {
+	return contentRedactor.JSON(body)
+}
+
+// RedactContentText 按内容采集规则处理文本
+func RedactContentText(s string) string {
+	return contentRedactor.Text(s)
+}
+
+// maskHeader 授权类内容只保留 token 首尾几位
+func maskHeader(value string) string {
+	if value == "" {
+		return ""
+	}
+	if i := strings.IndexByte(value, ' '); i > 0 {
+		return value[:i+1] + util.MaskSecret(value[i+1:])
+	}
+	return util.MaskSecret(value)
+}
+
+func maskValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case string:
+		if strings.Contains(val, "****") {
+			// 已脱敏的内容保持不变
+			return val
+		}
+		// 密钥类内容保留首尾几位便于排查，其他内容只记录长度
+		if len(val) > 64 || strings.ContainsAny(val, " \t\n") {
+			return fmt.Sprintf("[REDACTED, %d bytes]", len(val))
+		}
+		return util.MaskSecret(val)
+	default:
+		return "[REDACTED]"
+	}
+}
+
+// parseJSONPath 解析 messages[*].content 形式的路径
+func parseJSONPath(p string) []string {
+	p = strings.TrimPrefix(strings.TrimSpace(p), "$.")
+	var segments []string
+	for _, part := range strings.Split(p, ".") {
+		wildcards := 0
+		for strings.HasSuffix(part, "[*]") {
+			part = strings.TrimSuffix(part, "[*]")
+			wildcards++
+		}
+		if part != "" {
+			segments = append(segments, strings.ToLower(part))
+		}
+		for i := 0; i < wildcards; i++ {
+			segments = append(segments, "*")
+		}
+	}
+	return segments
+}
+
+// luhnValid 校验银行卡号，忽略空格和连字符
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+func segmentsEqual(a, b []string) bool {
+	for i := range a {
+		if a[i] != "*" && a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func lowerSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[strings.ToLower(strings.TrimSpace(item))] = true
+	}
+	return set
+}
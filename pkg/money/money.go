@@ -0,0 +1,102 @@
+// pkg/money/money.go
+package money
+
+import (
+	"math"
+
+	"llmapisrv/config"
+)
+
+// DefaultQuotaPerUSD New API 默认的每美元额度
+const DefaultQuotaPerUSD = 500000
+
+var (
+	quotaPerUSD  int64   = DefaultQuotaPerUSD
+	currency             = "USD"
+	symbol               = "$"
+	exchangeRate float64 = 1 // 1美元折合的展示货币
+)
+
+// Setup 根据配置初始化换算比例，未配置的项使用默认值
+func Setup(cfg config.Money) {
+	if cfg.QuotaPerUSD > 0 {
+		quotaPerUSD = cfg.QuotaPerUSD
+	}
+	if cfg.DisplayCurrency != "" {
+		currency = cfg.DisplayCurrency
+		symbol = cfg.CurrencySymbol
+	}
+	if cfg.USDExchangeRate > 0 {
+		exchangeRate = cfg.USDExchangeRate
+	}
+}
+
+// Amount 额度的统一展示结构，即响应中的 *_v2 金额字段
+type Amount struct {
+	Quota    int64   `json:"quota"`    // 原始额度
+	USD      float64 `json:"usd"`      // 美元
+	Amount   float64 `json:"amount"`   // 展示货币金额
+	Currency string  `json:"currency"` // 展示货币
+	Symbol   string  `json:"symbol"`
+}
+
+// FromQuota 按额度构建展示结构
+func FromQuota(quota int64) Amount {
+	return Amount{
+		Quota:    quota,
+		USD:      round(QuotaToUSD(quota)),
+		Amount:   round(QuotaToDisplay(quota)),
+		Currency: currency,
+		Symbol:   symbol,
+	}
+}
+
+// FromUSD 按美元金额构建展示结构
+func FromUSD(usd float64) Amount {
+	return FromQuota(USDToQuota(usd))
+}
+
+// QuotaPerUSD 每美元额度
+func QuotaPerUSD() int64 {
+	return quotaPerUSD
+}
+
+// Currency 展示货币
+func Currency() string {
+	return currency
+}
+
+// Symbol 展示货币符号
+func Symbol() string {
+	return symbol
+}
+
+// ExchangeRate 1美元折合的展示货币
+func ExchangeRate() float64 {
+	return exchangeRate
+}
+
+// QuotaToUSD 额度换算为美元
+func QuotaToUSD(quota int64) float64 {
+	return float64(quota) / float64(quotaPerUSD)
+}
+
+// USDToQuota 美元换算为额度
+func USDToQuota(usd float64) int64 {
+	return int64(math.Round(usd * float64(quotaPerUSD)))
+}
+
+// QuotaToDisplay 额度换算为展示货币金额
+func QuotaToDisplay(quota int64) float64 {
+	return QuotaToUSD(quota) * exchangeRate
+}
+
+// DisplayToQuota 展示货币金额换算为额度
+func DisplayToQuota(amount float64) int64 {
+	return USDToQuota(amount / exchangeRate)
+}
+
+// round 保留6位小数
+func round(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
@@ -0,0 +1,135 @@
+// pkg/notifier/notifier.go
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"text/template"
+
+	"llmapisrv/config"
+)
+
+// 邮件事件，模板文件名为 <事件>.<语言>.tmpl
+const (
+	EventBalanceLow       = "balance_low"       // 余额低于阈值
+	EventAccountExpiring  = "account_expiring"  // 账户即将到期
+	EventSyncFailed       = "sync_failed"       // 管理员告警：同步失败
+	EventRedeemBruteforce = "redeem_bruteforce" // 管理员告警：疑似兑换码爆破
+)
+
+// 模板语言
+const (
+	LangZH = "zh"
+	LangEN = "en"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// Message 待发送的邮件
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Sender 邮件发送后端
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Notifier 邮件通知，按事件渲染模板并发送，可在定时任务和管理员操作中复用
+type Notifier struct {
+	cfg       config.Email
+	sender    Sender
+	templates map[string]*template.Template // 按文件名索引，每个文件单独解析
+}
+
+// New 根据配置创建通知器，未启用时 Notify 直接返回
+func New(cfg config.Email) (*Notifier, error) {
+	return NewWithSender(cfg, NewSMTPSender(cfg))
+}
+
+// NewWithSender 使用指定的发送后端创建通知器
+func NewWithSender(cfg config.Email, sender Sender) (*Notifier, error) {
+	files, err := fs.Glob(templateFS, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// 每个模板文件都定义 subject、body 块，需分别解析避免互相覆盖
+	templates := make(map[string]*template.Template, len(files))
+	for _, file := range files {
+		t, err := template.ParseFS(templateFS, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse email template %s: %w", file, err)
+		}
+		templates[path.Base(file)] = t
+	}
+
+	return &Notifier{
+		cfg:       cfg,
+		sender:    sender,
+		templates: templates,
+	}, nil
+}
+
+// Enabled 判断事件是否启用邮件通知
+func (n *Notifier) Enabled(event string) bool {
+	return n != nil && n.cfg.Enabled && n.cfg.Events[event]
+}
+
+// Notify 渲染事件模板并发送给指定收件人，lang 为空时使用配置的默认语言
+func (n *Notifier) Notify(ctx context.Context, event, lang string, to []string, data map[string]interface{}) error {
+	if !n.Enabled(event) || len(to) == 0 {
+		return nil
+	}
+
+	msg, err := n.render(event, lang, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+
+	return n.sender.Send(ctx, msg)
+}
+
+// NotifyAdmins 发送管理员告警
+func (n *Notifier) NotifyAdmins(ctx context.Context, event string, data map[string]interface{}) error {
+	if n == nil {
+		return nil
+	}
+	return n.Notify(ctx, event, "", n.cfg.AdminRecipients, data)
+}
+
+// render 渲染模板，模板中以 subject 和 body 两个块分别定义主题和正文
+func (n *Notifier) render(event, lang string, data map[string]interface{}) (*Message, error) {
+	if lang == "" {
+		lang = n.cfg.Language
+	}
+	if lang != LangEN {
+		lang = LangZH
+	}
+
+	t, ok := n.templates[fmt.Sprintf("%s.%s.tmpl", event, lang)]
+	if !ok {
+		return nil, fmt.Errorf("email template not found: %s.%s", event, lang)
+	}
+
+	var subject, body bytes.Buffer
+	if err := t.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := t.ExecuteTemplate(&body, "body", data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject: subject.String(),
+		Body:    body.String(),
+	}, nil
+}
@@ -0,0 +1,126 @@
+// pkg/notifier/smtp.go
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"llmapisrv/config"
+)
+
+// SMTPSender SMTP 发送后端，支持 465 隐式 TLS 和 STARTTLS，未配置用户名时不认证（便于对接本地 SMTP 测试服务）
+type SMTPSender struct {
+	cfg config.Email
+	// rootCAs 校验服务端证书使用的根证书，为空时使用系统根证书（测试中信任自签名证书）
+	rootCAs *x509.CertPool
+}
+
+func NewSMTPSender(cfg config.Email) *SMTPSender {
+	return &SMTPSender{
+		cfg: cfg,
+	}
+}
+
+// Send 发送纯文本邮件
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	var conn net.Conn
+	var err error
+	if s.cfg.ImplicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, s.tlsConfig())
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("smtp dial failed: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake failed: %w", err)
+	}
+	defer client.Close()
+
+	if !s.cfg.ImplicitTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(s.tlsConfig()); err != nil {
+				return fmt.Errorf("smtp starttls failed: %w", err)
+			}
+		}
+	}
+
+	if s.cfg.Username != "" {
+		auth := smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp rcpt %s failed: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.buildMessage(msg)); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func (s *SMTPSender) tlsConfig() *tls.Config {
+	return &tls.Config{ServerName: s.cfg.Host, RootCAs: s.rootCAs}
+}
+
+// buildMessage 构建邮件内容，主题和正文按 UTF-8 编码
+func (s *SMTPSender) buildMessage(msg *Message) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("From: " + s.cfg.From + "\r\n")
+	buf.WriteString("To: " + strings.Join(msg.To, ", ") + "\r\n")
+	buf.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", msg.Subject) + "\r\n")
+	buf.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+	buf.WriteString("\r\n")
+
+	// base64 正文每行不超过76个字符
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+
+	return buf.Bytes()
+}
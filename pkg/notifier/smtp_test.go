@@ -0,0 +1,361 @@
+// pkg/notifier/smtp_test.go
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"llmapisrv/config"
+)
+
+// smtpSession 测试 SMTP 服务收到的一封邮件
+type smtpSession struct {
+	tls  bool   // 投递时连接是否已加密
+	auth string // AUTH PLAIN 解码后的 "\x00用户名\x00密码"，未认证时为空
+	from string
+	rcpt []string
+	data string
+}
+
+// testSMTPServer 进程内的最小 SMTP 服务，每个连接处理一封邮件
+type testSMTPServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config // 非空时对外声明 STARTTLS
+	sessions  chan smtpSession
+	errs      chan error
+}
+
+func newTestSMTPServer(t *testing.T, implicitTLS, startTLS bool) (*testSMTPServer, *x509.CertPool) {
+	t.Helper()
+	cert, pool := newTestCertificate(t)
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	var listener net.Listener
+	var err error
+	if implicitTLS {
+		listener, err = tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &testSMTPServer{
+		listener: listener,
+		sessions: make(chan smtpSession, 1),
+		errs:     make(chan error, 1),
+	}
+	if startTLS {
+		server.tlsConfig = tlsConfig
+	}
+	go server.serve(implicitTLS)
+	return server, pool
+}
+
+func (s *testSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *testSMTPServer) serve(implicitTLS bool) {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		s.errs <- err
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	session := smtpSession{tls: implicitTLS}
+	r := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		io.WriteString(conn, strings.Join(lines, "\r\n")+"\r\n")
+	}
+
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			s.errs <- err
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO":
+			lines := []string{"250-localhost"}
+			if s.tlsConfig != nil && !session.tls {
+				lines = append(lines, "250-STARTTLS")
+			}
+			reply(append(lines, "250 AUTH PLAIN")...)
+		case "STARTTLS":
+			reply("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				s.errs <- err
+				return
+			}
+			conn = tlsConn
+			r = bufio.NewReader(conn)
+			session.tls = true
+		case "AUTH":
+			fields := strings.Fields(line)
+			if len(fields) != 3 || strings.ToUpper(fields[1]) != "PLAIN" {
+				reply("504 unsupported auth")
+				continue
+			}
+			decoded, err := base64.StdEncoding.DecodeString(fields[2])
+			if err != nil {
+				reply("501 invalid auth")
+				continue
+			}
+			session.auth = string(decoded)
+			reply("235 authenticated")
+		case "MAIL":
+			session.from = strings.Trim(strings.TrimPrefix(line[len("MAIL"):], " FROM:"), "<>")
+			reply("250 ok")
+		case "RCPT":
+			session.rcpt = append(session.rcpt, strings.Trim(strings.TrimPrefix(line[len("RCPT"):], " TO:"), "<>"))
+			reply("250 ok")
+		case "DATA":
+			reply("354 end with <CRLF>.<CRLF>")
+			var data strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					s.errs <- err
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			session.data = data.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			s.sessions <- session
+			return
+		default:
+			reply("502 unknown command")
+		}
+	}
+}
+
+// wait 等待一封邮件投递完成
+func (s *testSMTPServer) wait(t *testing.T) smtpSession {
+	t.Helper()
+	select {
+	case session := <-s.sessions:
+		return session
+	case err := <-s.errs:
+		t.Fatalf("smtp server: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("smtp server: timed out waiting for message")
+	}
+	return smtpSession{}
+}
+
+// newTestCertificate 生成 127.0.0.1 的自签名证书
+func newTestCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+// decodeMessage 解析收到的邮件，返回解码后的主题和正文
+func decodeMessage(t *testing.T, data string) (string, string) {
+	t.Helper()
+	parsed, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("decode subject: %v", err)
+	}
+	if encoding := parsed.Header.Get("Content-Transfer-Encoding"); encoding != "base64" {
+		t.Fatalf("Content-Transfer-Encoding = %q, want base64", encoding)
+	}
+	body, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, parsed.Body))
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	return subject, string(body)
+}
+
+func TestSMTPSenderSend(t *testing.T) {
+	tests := []struct {
+		name        string
+		implicitTLS bool
+		startTLS    bool
+		username    string
+		wantTLS     bool
+	}{
+		// 本地回环地址上 PlainAuth 允许明文认证
+		{name: "plain", username: "notify@example.com"},
+		{name: "starttls", startTLS: true, username: "notify@example.com", wantTLS: true},
+		{name: "implicit tls", implicitTLS: true, username: "notify@example.com", wantTLS: true},
+		{name: "no auth"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, pool := newTestSMTPServer(t, tt.implicitTLS, tt.startTLS)
+			sender := NewSMTPSender(config.Email{
+				Host:        "127.0.0.1",
+				Port:        server.port(),
+				Username:    tt.username,
+				Password:    "secret",
+				From:        "notify@example.com",
+				ImplicitTLS: tt.implicitTLS,
+			})
+			sender.rootCAs = pool
+
+			msg := &Message{
+				To:      []string{"alice@example.com", "bob@example.com"},
+				Subject: "余额不足提醒",
+				// 超过一行 base64 长度，覆盖正文折行
+				Body: strings.Repeat("您好，您的余额不足。\n", 8),
+			}
+			if err := sender.Send(context.Background(), msg); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+
+			session := server.wait(t)
+			if session.tls != tt.wantTLS {
+				t.Errorf("delivered over tls = %v, want %v", session.tls, tt.wantTLS)
+			}
+			wantAuth := ""
+			if tt.username != "" {
+				wantAuth = "\x00" + tt.username + "\x00secret"
+			}
+			if session.auth != wantAuth {
+				t.Errorf("auth = %q, want %q", session.auth, wantAuth)
+			}
+			if session.from != "notify@example.com" {
+				t.Errorf("mail from = %q, want notify@example.com", session.from)
+			}
+			if strings.Join(session.rcpt, ",") != "alice@example.com,bob@example.com" {
+				t.Errorf("rcpt = %v, want alice and bob", session.rcpt)
+			}
+
+			subject, body := decodeMessage(t, session.data)
+			if subject != msg.Subject {
+				t.Errorf("subject = %q, want %q", subject, msg.Subject)
+			}
+			if body != msg.Body {
+				t.Errorf("body = %q, want %q", body, msg.Body)
+			}
+		})
+	}
+}
+
+// recordingSender 记录待发送邮件，不实际投递
+type recordingSender struct {
+	messages []*Message
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg *Message) error {
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func TestNotifyRendersTemplates(t *testing.T) {
+	events := map[string]map[string]interface{}{
+		EventBalanceLow:       {"Balance": "1.50", "Threshold": "5.00"},
+		EventAccountExpiring:  {"DaysLeft": 3, "ExpiredAt": "2026-10-22 00:00:00"},
+		EventSyncFailed:       {"Task": "sync_users", "Time": "2026-10-19 12:00:00", "Error": "connection refused"},
+		EventRedeemBruteforce: {"UserID": 42, "IP": "203.0.113.7", "Failures": 10, "Time": "2026-10-19 12:00:00"},
+	}
+	subjects := map[string]map[string]string{
+		EventBalanceLow:       {LangZH: "余额不足提醒：当前余额 1.50", LangEN: "Low balance: 1.50 remaining"},
+		EventAccountExpiring:  {LangZH: "账户即将到期：剩余 3 天", LangEN: "Your API key expires in 3 days"},
+		EventSyncFailed:       {LangZH: "[告警] 同步任务失败：sync_users", LangEN: "[Alert] Sync task failed: sync_users"},
+		EventRedeemBruteforce: {LangZH: "[告警] 疑似兑换码爆破：用户 42", LangEN: "[Alert] Suspected redemption code brute-force: user 42"},
+	}
+
+	cfg := config.Email{Enabled: true, Language: LangZH, Events: map[string]bool{}}
+	for event := range events {
+		cfg.Events[event] = true
+	}
+	sender := &recordingSender{}
+	n, err := NewWithSender(cfg, sender)
+	if err != nil {
+		t.Fatalf("new notifier: %v", err)
+	}
+
+	for event, data := range events {
+		for _, lang := range []string{LangZH, LangEN} {
+			sender.messages = nil
+			if err := n.Notify(context.Background(), event, lang, []string{"alice@example.com"}, data); err != nil {
+				t.Fatalf("%s/%s: notify: %v", event, lang, err)
+			}
+			if len(sender.messages) != 1 {
+				t.Fatalf("%s/%s: sent %d messages, want 1", event, lang, len(sender.messages))
+			}
+			msg := sender.messages[0]
+			if want := subjects[event][lang]; msg.Subject != want {
+				t.Errorf("%s/%s: subject = %q, want %q", event, lang, msg.Subject, want)
+			}
+			if strings.Contains(msg.Body, "<no value>") {
+				t.Errorf("%s/%s: body has missing fields:\n%s", event, lang, msg.Body)
+			}
+			for _, value := range data {
+				if s := toString(value); !strings.Contains(msg.Body, s) {
+					t.Errorf("%s/%s: body missing %q:\n%s", event, lang, s, msg.Body)
+				}
+			}
+		}
+	}
+}
+
+func toString(v interface{}) string {
+	if i, ok := v.(int); ok {
+		return strconv.Itoa(i)
+	}
+	return v.(string)
+}
@@ -194,6 +194,15 @@ func (c *OSSClient) DeleteFile(ctx context.Context, fileName string) error {
 	return nil
 }
 
+// CheckHealth 检查 OSS 是否可访问、密钥是否有效
+// 查询一个不存在的对象：密钥有效时返回不存在，无效时返回 403
+func (c *OSSClient) CheckHealth(ctx context.Context) error {
+	if _, err := c.bucket.IsObjectExist("healthz-probe", oss.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to access bucket: %w", err)
+	}
+	return nil
+}
+
 // getPublicDownloadURL 获取公开下载URL
 func (c *OSSClient) getPublicDownloadURL(fileName string) string {
 	if fileName == "" {
@@ -0,0 +1,93 @@
+// pkg/payment/mock.go
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// MockProvider 模拟支付渠道，仅用于本地测试，只能在 server.mode 为 debug 时启用
+// 下单返回的支付地址即为已签名的异步通知地址，对其发起 POST 请求即视为支付成功
+type MockProvider struct {
+	secret string
+}
+
+func NewMockProvider(secret string) *MockProvider {
+	return &MockProvider{
+		secret: secret,
+	}
+}
+
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+// CreatePayment 生成带签名的模拟支付地址
+func (p *MockProvider) CreatePayment(ctx context.Context, req *PaymentRequest) (*PaymentResult, error) {
+	params := url.Values{}
+	params.Set("order_no", req.OrderNo)
+	params.Set("trade_no", "MOCK"+req.OrderNo)
+	params.Set("amount", strconv.FormatInt(req.Amount, 10))
+	params.Set("trade_status", "SUCCESS")
+	params.Set("sign", p.sign(params))
+
+	payURL := req.NotifyURL + "?" + params.Encode()
+	return &PaymentResult{
+		PayURL: payURL,
+		QRCode: payURL,
+	}, nil
+}
+
+// ParseNotify 校验签名并解析通知参数
+func (p *MockProvider) ParseNotify(r *http.Request) (*Notification, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	params := r.Form
+
+	if !hmac.Equal([]byte(params.Get("sign")), []byte(p.sign(params))) {
+		return nil, fmt.Errorf("invalid sign")
+	}
+
+	amount, err := strconv.ParseInt(params.Get("amount"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount: %w", err)
+	}
+
+	return &Notification{
+		OrderNo: params.Get("order_no"),
+		TradeNo: params.Get("trade_no"),
+		Amount:  amount,
+		Paid:    params.Get("trade_status") == "SUCCESS",
+	}, nil
+}
+
+func (p *MockProvider) AckNotify(w http.ResponseWriter, success bool) {
+	if success {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("success"))
+		return
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write([]byte("fail"))
+}
+
+// sign 对除 sign 外的参数按 key 排序后做 HMAC-SHA256
+func (p *MockProvider) sign(params url.Values) string {
+	values := url.Values{}
+	for k, v := range params {
+		if k != "sign" {
+			values[k] = v
+		}
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.secret))
+	mac.Write([]byte(values.Encode())) // Encode 按 key 排序
+	return hex.EncodeToString(mac.Sum(nil))
+}
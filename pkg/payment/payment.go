@@ -0,0 +1,43 @@
+// pkg/payment/payment.go
+package payment
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// PaymentProvider 支付渠道，接入支付宝、微信支付等渠道时实现该接口
+type PaymentProvider interface {
+	// Name 渠道名称，用于下单时选择渠道和拼接异步通知地址
+	Name() string
+	// CreatePayment 向渠道下单，返回支付地址或二维码内容
+	CreatePayment(ctx context.Context, req *PaymentRequest) (*PaymentResult, error)
+	// ParseNotify 校验异步通知签名并解析，签名不正确时返回错误
+	ParseNotify(r *http.Request) (*Notification, error)
+	// AckNotify 按渠道要求应答异步通知，success 为 false 时渠道会重试通知
+	AckNotify(w http.ResponseWriter, success bool)
+}
+
+// PaymentRequest 下单参数
+type PaymentRequest struct {
+	OrderNo    string
+	Amount     int64 // 金额（单位：分）
+	Subject    string
+	NotifyURL  string
+	ExpireTime time.Time
+}
+
+// PaymentResult 下单结果，按渠道返回支付地址或二维码内容
+type PaymentResult struct {
+	PayURL string `json:"pay_url,omitempty"`
+	QRCode string `json:"qr_code,omitempty"`
+}
+
+// Notification 异步通知内容
+type Notification struct {
+	OrderNo string
+	TradeNo string // 渠道交易号
+	Amount  int64  // 实付金额（单位：分）
+	Paid    bool   // 是否支付成功
+}
@@ -27,7 +27,7 @@ func (q *RedisQueue) Push(queue string, data interface{}) error {
 	if err != nil {
 		return err
 	}
-	logger.Infof("RedisQueue Push, body: %v", string(jsonData))
+	logger.Infof("RedisQueue Push, queue: %v, body: %v", queue, logger.RedactBody(jsonData, 512))
 
 	return q.client.RPush(context.Background(), queue, jsonData).Err()
 }
@@ -43,11 +43,10 @@ func (q *RedisQueue) ProcessQueue(queue string, handler func([]byte) error) {
 			}
 			continue
 		}
-		logger.Infof("ProcessQueue, body data: %v", result)
-
 		if len(result) < 2 {
 			continue
 		}
+		logger.Infof("ProcessQueue, queue: %v, body: %v", queue, logger.RedactBody([]byte(result[1]), 512))
 
 		// 处理消息
 		data := []byte(result[1])
@@ -0,0 +1,74 @@
+// pkg/tracing/redis.go
+package tracing
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedisHook 为 Redis 命令创建 span，只记录命令名不记录键和参数（键中可能含有密钥）
+// 与 GormPlugin 一样，只在上下文中已有 span 时记录
+type RedisHook struct{}
+
+// 保存本 hook 创建的 span，避免结束上层的 span
+type redisSpanKey struct{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil
+	}
+	ctx, span := Start(ctx, "redis."+cmd.Name(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", cmd.Name()),
+		),
+	)
+	return context.WithValue(ctx, redisSpanKey{}, span), nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	endRedisSpan(ctx, cmd.Err())
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil
+	}
+	ctx, span := Start(ctx, "redis.pipeline",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "redis"),
+			attribute.Int("db.redis.num_cmd", len(cmds)),
+		),
+	)
+	return context.WithValue(ctx, redisSpanKey{}, span), nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && cmd.Err() != redis.Nil {
+			err = cmd.Err()
+			break
+		}
+	}
+	endRedisSpan(ctx, err)
+	return nil
+}
+
+func endRedisSpan(ctx context.Context, err error) {
+	span, ok := ctx.Value(redisSpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	if err == redis.Nil {
+		// 键不存在不视为错误
+		err = nil
+	}
+	End(span, err)
+}
@@ -0,0 +1,59 @@
+// pkg/util/ip.go
+package util
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseIPNet 解析IP或CIDR，单个IP按/32或/128处理
+func ParseIPNet(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		_, ipNet, err := net.ParseCIDR(s)
+		return ipNet, err
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP or CIDR: %s", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// ValidateIPList 校验IP/CIDR列表格式
+func ValidateIPList(list []string) error {
+	for _, s := range list {
+		if _, err := ParseIPNet(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IPAllowed 判断IP是否在白名单中，白名单为空时不限制
+func IPAllowed(ip string, allowList []string) bool {
+	if len(allowList) == 0 {
+		return true
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, s := range allowList {
+		ipNet, err := ParseIPNet(s)
+		if err != nil {
+			continue
+		}
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
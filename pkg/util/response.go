@@ -13,8 +13,12 @@ const (
 	FailCode = 400
 	// UnauthorizedCode 未授权状态码
 	UnauthorizedCode = 401
+	// ForbiddenCode 无权限状态码
+	ForbiddenCode = 403
 	// ServerErrorCode 服务器错误状态码
 	ServerErrorCode = 500
+	// ConflictCode 资源冲突状态码
+	ConflictCode = 409
 	// 请求次数限制错误
 	LimitErrorCode = 1001
 )
@@ -0,0 +1,10 @@
+// pkg/util/sanitize.go
+package util
+
+// MaskSecret 只保留密钥的首尾几位
+func MaskSecret(s string) string {
+	if len(s) <= 8 {
+		return "****"
+	}
+	return s[:4] + "****" + s[len(s)-4:]
+}
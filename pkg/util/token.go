@@ -2,6 +2,8 @@
 package util
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"strings"
 )
 
@@ -17,3 +19,9 @@ func ExtractToken(authHeader string) string {
 func RemoveStartSk(apiKey string) string {
 	return strings.Replace(apiKey, "sk-", "", 1)
 }
+
+// HashToken 计算token的SHA-256，数据库和缓存中只保存哈希
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,106 @@
+// pkg/webhook/webhook.go
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Sign 计算签名：HMAC-SHA256(secret, timestamp + "." + body)
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateURL 校验 webhook 地址，只允许 http/https
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("webhook url must use http or https")
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("webhook url host is required")
+	}
+	return nil
+}
+
+// Client webhook 投递客户端
+type Client struct {
+	httpClient *http.Client
+}
+
+// NewClient 创建投递客户端，allowPrivate 为 false 时拒绝连接内网和本机地址
+func NewClient(timeout time.Duration, allowPrivate bool) *Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		// 在建立连接时校验解析后的IP，避免通过域名解析绕过
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+				return fmt.Errorf("webhook target address not allowed: %s", host)
+			}
+			return nil
+		}
+	}
+
+	return &Client{
+		httpClient: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext: dialer.DialContext,
+			},
+			// 不跟随重定向，防止跳转到内网地址
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Send 投递事件，返回响应状态码，非2xx视为失败
+func (c *Client) Send(ctx context.Context, targetURL, secret, eventID, eventType string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, targetURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", eventID)
+	req.Header.Set("X-Webhook-Event", eventType)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(secret, timestamp, body))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -79,6 +79,18 @@ async function getPricing() {
   return apiRequest('/api/pricing');
 }
 
+// 额度换算配置，由 /api/currency 提供，加载后缓存
+let currencyConfig = null;
+
+// 获取额度换算配置
+async function getCurrency() {
+  if (!currencyConfig) {
+    const response = await apiRequest('/api/currency');
+    currencyConfig = response.data;
+  }
+  return currencyConfig;
+}
+
 // 获取健康状态
 async function getStatus() {
   return apiRequest('/api/about');
@@ -141,9 +153,33 @@ function formatDate(timestamp) {
   });
 }
 
-// 格式化金额
-function formatAmount(amount) {
-  return `$${parseFloat(amount).toFixed(6)}`;
+// 格式化接口返回的 v2 金额字段
+function formatMoney(money, digits = 3) {
+  return `${money.symbol}${money.amount.toFixed(digits)}`;
+}
+
+// 旧版美元字段换算为 v2 金额结构，接口未返回 v2 字段时使用，需先调用 getCurrency 加载换算配置
+function moneyFromUSD(usd) {
+  const cfg = currencyConfig || { quota_per_usd: 500000, currency: 'USD', symbol: '$', usd_exchange_rate: 1 };
+  return {
+    quota: Math.round(usd * cfg.quota_per_usd),
+    usd,
+    amount: usd * cfg.usd_exchange_rate,
+    currency: cfg.currency,
+    symbol: cfg.symbol
+  };
+}
+
+// 美元换算为展示货币并格式化，需先调用 getCurrency 加载换算配置
+function formatUSD(usd, digits = 6) {
+  const cfg = currencyConfig || { quota_per_usd: 500000, symbol: '$', usd_exchange_rate: 1 };
+  return `${cfg.symbol}${(usd * cfg.usd_exchange_rate).toFixed(digits)}`;
+}
+
+// 额度换算为展示货币并格式化，需先调用 getCurrency 加载换算配置
+function formatQuota(quota, digits = 6) {
+  const cfg = currencyConfig || { quota_per_usd: 500000 };
+  return formatUSD(quota / cfg.quota_per_usd, digits);
 }
 
 // 登出
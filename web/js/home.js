@@ -55,11 +55,12 @@ document.addEventListener('DOMContentLoaded', function() {
   // 加载订阅数据
   async function loadSubscriptionData() {
     try {
+      await getCurrency();
       const response = await getSubscription();
       const data = response.data;
   
       // 填充数据
-      document.getElementById('total-quota').textContent = `$${data.hard_limit_usd.toFixed(3)}`;
+      document.getElementById('total-quota').textContent = formatMoney(subscriptionTotal(data));
   
       // 处理过期时间
       const expiryDate = data.access_until === 0 
@@ -72,21 +73,26 @@ document.addEventListener('DOMContentLoaded', function() {
     }
   }
   
+  // 总额度，未返回 v2 字段时按旧版 hard_limit_usd 换算
+  function subscriptionTotal(data) {
+    return data.hard_limit_v2 || moneyFromUSD(data.hard_limit_usd || 0);
+  }
+  
   // 加载使用情况数据
   async function loadUsageData() {
     try {
+      await getCurrency();
       const response = await getUsage();
       const data = response.data;
   
-      // 计算已使用额度 (total_usage 是美分)
-      const usedAmount = (data.total_usage / 100).toFixed(3);
-      document.getElementById('used-quota').textContent = `$${usedAmount}`;
+      // 已使用额度，未返回 v2 字段时按旧版美元×100 字段换算
+      const used = data.total_usage_v2 || moneyFromUSD((data.total_usage || 0) / 100);
+      document.getElementById('used-quota').textContent = formatMoney(used);
   
       // 计算剩余额度 (从订阅中获取总额)
       const subscriptionResponse = await getSubscription();
-      const totalAmount = subscriptionResponse.data.hard_limit_usd;
-      const remainingAmount = (totalAmount - (data.total_usage / 100)).toFixed(3);
-      document.getElementById('remaining-quota').textContent = `$${remainingAmount}`;
+      const total = subscriptionTotal(subscriptionResponse.data);
+      document.getElementById('remaining-quota').textContent = formatMoney({ ...total, amount: total.amount - used.amount });
   
     } catch (error) {
       console.error('加载使用情况数据失败:', error);
@@ -100,6 +106,7 @@ document.addEventListener('DOMContentLoaded', function() {
       document.getElementById('logs-loading').classList.remove('hidden');
       document.getElementById('logs-content').classList.add('hidden');
   
+      await getCurrency();
       const response = await getLogs(page, pageSize);
       const { data, meta } = response.data;
   
@@ -109,11 +116,6 @@ document.addEventListener('DOMContentLoaded', function() {
   
       if (data && data.length > 0) {
         data.forEach(log => {
-          // 计算花费 (tokens * price)
-          const totalTokens = log.prompt_tokens + log.completion_tokens;
-          // 假设 quota 是以 1/100 美分计算的
-          const cost = log.quota / 500000;
-  
           const row = document.createElement('tr');
           row.innerHTML = `
             <td>${formatDate(log.created_at)}</td>
@@ -121,7 +123,7 @@ document.addEventListener('DOMContentLoaded', function() {
             <td>${log.use_time}秒</td>
             <td>${log.prompt_tokens}</td>
             <td>${log.completion_tokens}</td>
-            <td>${formatAmount(cost)}</td>
+            <td>${formatQuota(log.quota)}</td>
             <td>${log.content}</td>
           `;
           tableBody.appendChild(row);
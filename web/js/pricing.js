@@ -47,6 +47,7 @@ async function loadPricingData() {
         document.getElementById('pricing-loading').classList.remove('hidden');
         document.getElementById('pricing-content').classList.add('hidden');
 
+        await getCurrency();
         const response = await getPricing();
         const data = response.data;
 
@@ -102,9 +103,9 @@ function renderPricingTable(models) {
             // 模型价格
             const priceCell = document.createElement('td');
             // 计算价格 (基础价格为 $2 / 1M tokens)
-            const promptPrice = (model.model_ratio * 2).toFixed(6);
-            const completionPrice = (model.model_ratio * model.completion_ratio * 2).toFixed(6);
-            priceCell.innerHTML = `提示 $${promptPrice} / 1M tokens<br>补全 $${completionPrice} / 1M tokens`;
+            const promptPrice = formatUSD(model.model_ratio * 2);
+            const completionPrice = formatUSD(model.model_ratio * model.completion_ratio * 2);
+            priceCell.innerHTML = `提示 ${promptPrice} / 1M tokens<br>补全 ${completionPrice} / 1M tokens`;
 
             row.appendChild(availabilityCell);
             row.appendChild(nameCell);
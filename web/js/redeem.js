@@ -70,7 +70,7 @@ async function checkRedeemCode() {
 
         // 显示兑换码信息
         document.getElementById('code-quota').textContent = data.quota;
-        document.getElementById('code-amount').textContent = `$${data.amount}`;
+        document.getElementById('code-amount').textContent = formatMoney(data.amount_v2);
         document.getElementById('code-info').classList.remove('hidden');
 
         showResult('兑换码有效，可以兑换', 'success');
@@ -99,7 +99,7 @@ async function handleRedeemCode(e) {
         const data = response.data;
 
         // 显示兑换结果
-        showResult(`兑换成功！已添加 $${data.amount} 额度到您的账户`, 'success');
+        showResult(`兑换成功！已添加 ${formatMoney(data.amount_v2)} 额度到您的账户`, 'success');
 
         // 清空输入框
         codeInput.value = '';
@@ -56,8 +56,13 @@ async function loadStatusData() {
     const responseTime = Math.round(endTime - startTime);
 
     // 更新状态信息
-    document.getElementById('api-status').innerHTML = '<span class="badge badge-success">正常</span>';
+    const report = response.data || {};
+    document.getElementById('api-status').innerHTML = statusBadge(report.status);
     document.getElementById('response-time').textContent = `${responseTime} ms`;
+    document.getElementById('checked-at').textContent = report.checked_at
+      ? new Date(report.checked_at * 1000).toLocaleTimeString()
+      : '--';
+    renderComponentsTable(report.components || []);
 
     // 隐藏加载状态
     document.getElementById('status-loading').classList.add('hidden');
@@ -74,6 +79,52 @@ async function loadStatusData() {
   }
 }
 
+// 组件显示名称
+const componentNames = {
+  gateway_db: '网关数据库',
+  new_api_db: 'New API 数据库',
+  redis: '缓存',
+  new_api: '上游服务',
+  oss: '文件存储'
+};
+
+// 状态标签，degraded 表示非必需组件异常
+function statusBadge(status) {
+  switch (status) {
+    case 'ok':
+      return '<span class="badge badge-success">正常</span>';
+    case 'degraded':
+      return '<span class="badge badge-warning">部分异常</span>';
+    default:
+      return '<span class="badge badge-danger">异常</span>';
+  }
+}
+
+// 渲染组件状态表格
+function renderComponentsTable(components) {
+  const tableBody = document.getElementById('components-table-body');
+  tableBody.innerHTML = '';
+
+  components.forEach(component => {
+    const row = document.createElement('tr');
+
+    const nameCell = document.createElement('td');
+    nameCell.textContent = componentNames[component.name] || component.name;
+
+    const statusCell = document.createElement('td');
+    statusCell.innerHTML = statusBadge(component.status);
+
+    const latencyCell = document.createElement('td');
+    latencyCell.textContent = `${component.latency_ms} ms`;
+
+    row.appendChild(nameCell);
+    row.appendChild(statusCell);
+    row.appendChild(latencyCell);
+
+    tableBody.appendChild(row);
+  });
+}
+
 // 加载模型数据
 async function loadModelsData() {
   try {